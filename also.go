@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// extraOutput writes every result in a second format to a file, next to the output on stdout
+type extraOutput struct {
+	flag      string
+	formatter Formatter
+	w         io.Writer
+}
+
+// extraOutputs are the files given to -also-json and -also-markdown, opened at startup
+var extraOutputs []extraOutput
+
+// openExtraOutputs() creates the files of the -also-* flags. Any Formatter can be added here,
+// the files always go through an ansiStripper, so they never hold colors
+func openExtraOutputs() error {
+	for _, extra := range []struct {
+		flag      string
+		path      string
+		formatter Formatter
+	}{
+		{"also-json", *flagAlsoJSON, JSONFormatter{}},
+		{"also-markdown", *flagAlsoMarkdown, MarkdownFormatter{}},
+	} {
+		if extra.path == "" {
+			continue
+		}
+
+		file, err := os.Create(extra.path)
+		if err != nil {
+			return fmt.Errorf("Failed to create -%s file: %v", extra.flag, err)
+		}
+
+		extraOutputs = append(extraOutputs, extraOutput{flag: extra.flag, formatter: extra.formatter, w: newANSIStripper(file)})
+	}
+
+	return nil
+}
+
+// writeExtraOutputs() appends a result to every -also-* file
+func writeExtraOutputs(r Response) {
+	for _, extra := range extraOutputs {
+		if err := extra.formatter.Format(extra.w, r); err != nil {
+			fmt.Fprintf(stderr, "Failed to write -%s: %v\n", extra.flag, err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAlsoOutputs(t *testing.T) {
+	api := fixtureServer(t, "golang.json")
+
+	dir := t.TempDir()
+	jsonPath, markdownPath := filepath.Join(dir, "results.json"), filepath.Join(dir, "results.md")
+
+	// Colors are forced on stdout to check that they stay out of the files
+	stdout, stderr, code := runMain(t, "golang\nrob pike\n", "-api-base", api, "-color", "always",
+		"-also-json", jsonPath, "-also-markdown", markdownPath)
+	if code != exitFound || stderr != "" {
+		t.Fatalf("exited with %d and printed %q", code, stderr)
+	}
+
+	if strings.Count(stdout, "Go is a statically typed") != 2 || !strings.Contains(stdout, "\033[") {
+		t.Errorf("stdout does not hold both colored human readable results:\n%s", stdout)
+	}
+
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 || strings.Contains(string(data), "\033") {
+		t.Fatalf("-also-json wrote\n%q\nwant a line of JSON per result without colors", data)
+	}
+	for _, line := range lines {
+		var response Response
+		if err := json.Unmarshal([]byte(line), &response); err != nil || response.Heading != "Go (programming language)" {
+			t.Errorf("-also-json wrote the line %q: %v", line, err)
+		}
+	}
+
+	data, err = os.ReadFile(markdownPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(data), "## Go (programming language)") != 2 || strings.Contains(string(data), "\033") {
+		t.Errorf("-also-markdown wrote\n%q\nwant both results without colors", data)
+	}
+
+	_, stderr, code = runMain(t, "", "-api-base", api, "-also-json", filepath.Join(dir, "missing", "results.json"), "-s", "golang")
+	if code != exitUsage || !strings.Contains(stderr, "Failed to create -also-json file: ") {
+		t.Errorf("-also-json in a missing directory exited with %d and printed %q", code, stderr)
+	}
+}
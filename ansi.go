@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"io"
+)
+
+// ansiState is where an ansiStripper is within an escape sequence
+type ansiState int
+
+const (
+	ansiText   ansiState = iota // plain text
+	ansiEscape                  // after ESC
+	ansiCSI                     // inside ESC [ ... final byte, e.g. colors and cursor movement
+	ansiOSC                     // inside ESC ] ... BEL or ESC \, e.g. window titles and links
+	ansiOSCEsc                  // after ESC inside an OSC sequence
+)
+
+// ansiStripper is an io.Writer that removes ANSI escape sequences from the text written through
+// it, for output that is not going to a terminal. It keeps its state between writes, so a
+// sequence split across two writes is still removed
+type ansiStripper struct {
+	w     io.Writer
+	state ansiState
+}
+
+// newANSIStripper() wraps w so that everything written to it arrives without escape sequences
+func newANSIStripper(w io.Writer) *ansiStripper {
+	return &ansiStripper{w: w}
+}
+
+func (s *ansiStripper) Write(p []byte) (int, error) {
+	var plain bytes.Buffer
+
+	for _, c := range p {
+		switch s.state {
+		case ansiText:
+			if c == 0x1b {
+				s.state = ansiEscape
+			} else {
+				plain.WriteByte(c)
+			}
+		case ansiEscape:
+			switch c {
+			case '[':
+				s.state = ansiCSI
+			case ']':
+				s.state = ansiOSC
+			default:
+				// A two byte sequence such as ESC 7, which saves the cursor
+				s.state = ansiText
+			}
+		case ansiCSI:
+			// Parameter and intermediate bytes continue the sequence, 0x40-0x7e ends it
+			if c >= 0x40 && c <= 0x7e {
+				s.state = ansiText
+			}
+		case ansiOSC:
+			switch c {
+			case 0x07:
+				s.state = ansiText
+			case 0x1b:
+				s.state = ansiOSCEsc
+			}
+		case ansiOSCEsc:
+			if c == '\\' {
+				s.state = ansiText
+			} else {
+				s.state = ansiOSC
+			}
+		}
+	}
+
+	if _, err := s.w.Write(plain.Bytes()); err != nil {
+		return 0, err
+	}
+
+	// Report the whole input as written, the removed sequences were consumed on purpose
+	return len(p), nil
+}
+
+// stripANSI() removes the ANSI escape sequences, such as the TerminalColors, from text
+func stripANSI(text string) string {
+	var plain bytes.Buffer
+	newANSIStripper(&plain).Write([]byte(text))
+
+	return plain.String()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestANSIStripper(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain text", "Go is a programming language.", "Go is a programming language."},
+		{"color and reset", TerminalColors["Green"] + "Related topics:" + TerminalColors["Reset"], "Related topics:"},
+		{"true color", TrueColors["Blue"] + "https://go.dev" + TrueColors["Reset"], "https://go.dev"},
+		{"cursor movement", "\x1b[2K\x1b[1Gdone\x1b[3A", "done"},
+		{"cursor save and restore", "\x1b7saved\x1b8", "saved"},
+		{"title with BEL", "\x1b]0;golang\x07answer", "answer"},
+		{"title with ST", "\x1b]0;golang\x1b\\answer", "answer"},
+		{"newlines kept", "\x1b[31mone\x1b[0m\ntwo\n", "one\ntwo\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			n, err := newANSIStripper(&out).Write([]byte(test.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if n != len(test.input) {
+				t.Errorf("Write() reported %d bytes written, want all %d", n, len(test.input))
+			}
+
+			if out.String() != test.want {
+				t.Errorf("stripped %q to %q, want %q", test.input, out.String(), test.want)
+			}
+		})
+	}
+}
+
+// TestANSIStripperSplitWrites checks that a sequence split across writes is still removed
+func TestANSIStripperSplitWrites(t *testing.T) {
+	var out bytes.Buffer
+	stripper := newANSIStripper(&out)
+
+	for _, part := range []string{"Go", "\x1b", "[3", "3m is", " fun\x1b]0;ti", "tle\x1b", "\\!"} {
+		stripper.Write([]byte(part))
+	}
+
+	if want := "Go is fun!"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseDelimiter() converts the value of the -delim flag into the string used to split
+// batch input into individual queries. The escapes \n, \0 and \t are recognised, otherwise
+// the delimiter must be exactly one character
+func parseDelimiter(delim string) (string, error) {
+	switch delim {
+	case `\n`:
+		return "\n", nil
+	case `\0`:
+		return "\x00", nil
+	case `\t`:
+		return "\t", nil
+	}
+
+	if utf8.RuneCountInString(delim) != 1 {
+		return "", fmt.Errorf("Invalid delimiter %q: expected a single character or one of \\n, \\0, \\t", delim)
+	}
+
+	return delim, nil
+}
+
+// splitQueries() splits batch input on the delimiter, trimming whitespace from each query
+// and dropping empty tokens such as the one left behind by a trailing delimiter
+func splitQueries(input string, delim string) []string {
+	queries := []string{}
+
+	for _, token := range strings.Split(input, delim) {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+
+		queries = append(queries, token)
+	}
+
+	return queries
+}
+
+// stdinIsPiped() reports whether os.Stdin is a pipe or file rather than a terminal
+func stdinIsPiped() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice == 0
+}
+
+// openBatchInput() returns the reader that batch queries are read from, either the file
+// given to -f or os.Stdin when the path is "-" or empty
+func openBatchInput(path string) (io.ReadCloser, error) {
+	if path == "" || path == "-" {
+		return os.Stdin, nil
+	}
+
+	return os.Open(path)
+}
+
+// runBatch() reads every query from input and processes them one after another. A query that
+// fails does not stop the batch, but the batch fails once every query has been run
+func runBatch(input io.Reader, delim string, options Options) error {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return err
+	}
+
+	_, human := outputFormatter.(HumanFormatter)
+
+	empty, failed := 0, 0
+	for i, query := range splitQueries(string(data), delim) {
+		if i > 0 && human && *flagSeparator != "" {
+			fmt.Fprintln(os.Stdout, expandSeparator(*flagSeparator, query, i+1))
+		}
+
+		response, err := processAPIRequest(query, options)
+		if err == errOutputClosed {
+			return nil
+		}
+
+		// Go on with the other queries, the failure is reported once the batch is done
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			failed++
+			continue
+		}
+
+		if isEmptyResult(response) {
+			empty++
+		}
+	}
+
+	if *flagStats {
+		fmt.Fprintln(stderr, sessionStats.snapshot().latencySummary())
+	}
+
+	if *flagMetrics != "" {
+		if err := writeMetrics(*flagMetrics, sessionStats.snapshot()); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%s failed", plural(failed, "query", "queries"))
+	}
+
+	if empty > 0 && *flagFailOnEmpty {
+		return fmt.Errorf("No results for %s", plural(empty, "query", "queries"))
+	}
+
+	return nil
+}
+
+// expandSeparator() replaces %q in the -separator line with the query of the result that follows
+// it and %n with the position of that result in the batch, counting from 1
+func expandSeparator(separator string, query string, position int) string {
+	return strings.NewReplacer("%q", query, "%n", strconv.Itoa(position)).Replace(separator)
+}
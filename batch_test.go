@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitQueries(t *testing.T) {
+	tests := []struct {
+		name  string
+		delim string
+		input string
+		want  []string
+	}{
+		{"newline", `\n`, "golang\nrob pike\n", []string{"golang", "rob pike"}},
+		{"null", `\0`, "golang\x00rob pike\x00", []string{"golang", "rob pike"}},
+		{"comma", ",", "golang, rob pike ,ken thompson", []string{"golang", "rob pike", "ken thompson"}},
+		{"tab", `\t`, "golang\trob pike", []string{"golang", "rob pike"}},
+		{"empty tokens", ",", ",golang,,  ,rob pike,", []string{"golang", "rob pike"}},
+		{"no input", `\n`, "", []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			delim, err := parseDelimiter(test.delim)
+			if err != nil {
+				t.Fatalf("parseDelimiter(%q): %v", test.delim, err)
+			}
+
+			if got := splitQueries(test.input, delim); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("splitQueries(%q, %q) = %q, want %q", test.input, delim, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseDelimiterInvalid(t *testing.T) {
+	for _, delim := range []string{"", ";;", `\r`} {
+		if _, err := parseDelimiter(delim); err == nil {
+			t.Errorf("parseDelimiter(%q) accepted an invalid delimiter", delim)
+		}
+	}
+}
+
+func TestBatchFailures(t *testing.T) {
+	t.Run("every query fails", func(t *testing.T) {
+		_, stderr, code := runMain(t, "golang\nrob pike\n", "-api-base", closedAPI(t))
+		if code != exitError {
+			t.Errorf("exited with %d, want %d", code, exitError)
+		}
+
+		if !strings.Contains(stderr, "2 queries failed") {
+			t.Errorf("the failures were not counted, stderr is %q", stderr)
+		}
+	})
+
+	t.Run("one query fails", func(t *testing.T) {
+		golang := readFixture(t, "golang.json")
+		apiBase := stubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("q") == "rob pike" {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			serveJSON(golang)(w, r)
+		})
+
+		stdout, stderr, code := runMain(t, "golang\nrob pike\ngolang\n", "-api-base", apiBase, "-abstract-only")
+		if code != exitError {
+			t.Errorf("exited with %d, want %d", code, exitError)
+		}
+
+		// The queries around the failed one are still answered
+		if strings.Count(stdout, "Go is a statically typed") != 2 {
+			t.Errorf("printed %q, want both answers", stdout)
+		}
+
+		if !strings.Contains(stderr, "1 query failed") {
+			t.Errorf("stderr is %q", stderr)
+		}
+	})
+
+	t.Run("silent", func(t *testing.T) {
+		_, stderr, code := runMain(t, "golang\n", "-api-base", closedAPI(t), "-silent")
+		if code != exitError || stderr != "" {
+			t.Errorf("exited with %d and printed %q, want %d and nothing", code, stderr, exitError)
+		}
+	})
+}
+
+func TestSeparator(t *testing.T) {
+	api := fixtureServer(t, "golang.json")
+	input := "golang\nrob pike\ngo programming\n"
+
+	tests := []struct {
+		name  string
+		args  []string
+		lines []string
+	}{
+		{"default", nil, []string{"────────────────────", "────────────────────"}},
+		{"interpolated", []string{"-separator", "== %n: %q =="}, []string{"== 2: rob pike ==", "== 3: go programming =="}},
+		{"none", []string{"-separator", ""}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stdout, stderr, code := runMain(t, input, append([]string{"-api-base", api}, test.args...)...)
+			if code != exitFound {
+				t.Fatalf("exited with %d: %s", code, stderr)
+			}
+
+			// Each separator is between two results, so the results come before and after it
+			lines := strings.Split(stdout, "\n")
+			var separators []string
+			for i, line := range lines {
+				if strings.HasPrefix(line, "──") || strings.HasPrefix(line, "==") {
+					separators = append(separators, line)
+					if i == 0 || strings.TrimSpace(strings.Join(lines[i+1:], "")) == "" {
+						t.Errorf("the separator %q is not between two results", line)
+					}
+				}
+			}
+
+			if !reflect.DeepEqual(separators, test.lines) {
+				t.Errorf("the separators are %q, want %q", separators, test.lines)
+			}
+
+			if count := strings.Count(stdout, "Go is a statically typed"); count != 3 {
+				t.Errorf("printed %d results, want 3", count)
+			}
+		})
+	}
+
+	// Only the human readable output is separated, other formats must stay parseable
+	stdout, _, _ := runMain(t, input, "-api-base", api, "-json")
+	if strings.Contains(stdout, "────") {
+		t.Errorf("the JSON output holds a separator:\n%s", stdout)
+	}
+}
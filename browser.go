@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"runtime"
+)
+
+// openURL opens a link in the user's browser, it is a variable so the launch can be replaced
+var openURL = openBrowser
+
+// openBrowser() launches the operating system's default handler for the URL. The links come from
+// the API, which -api-base may point anywhere, so only web pages are opened: the handler would
+// as readily run a file: or smb: link
+func openBrowser(link string) error {
+	if parsed, err := url.Parse(link); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("Refusing to open %q: only http and https links are opened", link)
+	}
+
+	var command *exec.Cmd
+
+	switch runtime.GOOS {
+	case "windows":
+		command = exec.Command("rundll32", "url.dll,FileProtocolHandler", link)
+	case "darwin":
+		command = exec.Command("open", link)
+	default:
+		command = exec.Command("xdg-open", link)
+	}
+
+	if err := command.Start(); err != nil {
+		return fmt.Errorf("Failed to open %s: %v", link, err)
+	}
+
+	// Reap the launcher in the background so it does not linger as a zombie process
+	go command.Wait()
+
+	return nil
+}
+
+// bestURL() picks the most relevant link of a response: the redirect target of a bang query,
+// then the abstract's source, then the first related topic
+func bestURL(input Response) string {
+	if input.Redirect != "" {
+		return input.Redirect
+	}
+
+	if input.AbstractURL != "" {
+		return input.AbstractURL
+	}
+
+	for _, topic := range flattenTopics(input.RelatedTopics) {
+		if topic.FirstURL != "" {
+			return topic.FirstURL
+		}
+	}
+
+	return ""
+}
+
+// isRedirectOnly() reports whether the response carries nothing but a redirect, as bang queries
+// such as "!w golang" do when no_redirect=1
+func isRedirectOnly(input Response) bool {
+	return input.Redirect != "" && input.AbstractText == "" && input.Answer == "" && len(input.RelatedTopics) == 0
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// stubBrowser() replaces the browser launch for the duration of a test, recording every link
+// opened, and failing with err when it is not nil
+func stubBrowser(t *testing.T, err error) *[]string {
+	opened := []string{}
+
+	previous := openURL
+	openURL = func(link string) error {
+		opened = append(opened, link)
+		return err
+	}
+	t.Cleanup(func() { openURL = previous })
+
+	return &opened
+}
+
+func TestLucky(t *testing.T) {
+	setFlag(t, "open", "true")
+	apiServer(t, serveJSON(readFixture(t, "golang.json")))
+
+	previous := outputFormatter
+	outputFormatter = luckyFormatter{}
+	t.Cleanup(func() { outputFormatter = previous })
+
+	opened := stubBrowser(t, nil)
+
+	var err error
+	stdout := captureStdout(t, func() {
+		_, err = answerQuery("golang", defaultOptions())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// -open is implied by -lucky, the link must not be opened a second time
+	want := "https://en.wikipedia.org/wiki/Go_(programming_language)"
+	if len(*opened) != 1 || (*opened)[0] != want {
+		t.Errorf("opened %q, want only %q", *opened, want)
+	}
+
+	if stdout != "Opened "+want+"\n" {
+		t.Errorf("printed %q", stdout)
+	}
+}
+
+func TestLuckyFails(t *testing.T) {
+	apiServer(t, serveJSON(readFixture(t, "golang.json")))
+
+	previous := outputFormatter
+	outputFormatter = luckyFormatter{}
+	t.Cleanup(func() { outputFormatter = previous })
+
+	stubBrowser(t, errors.New("Failed to open the link: no browser"))
+
+	var err error
+	captureStdout(t, func() {
+		_, err = answerQuery("golang", defaultOptions())
+	})
+
+	var missing missingError
+	if err == nil || errors.As(err, &missing) {
+		t.Errorf("got the error %v, want the failed launch to fail the query", err)
+	}
+}
+
+func TestLuckyNothingToOpen(t *testing.T) {
+	apiBase := stubAPI(t, serveJSON(`{"AbstractText":"","RelatedTopics":[]}`))
+
+	_, stderr, code := runMain(t, "", "-api-base", apiBase, "-s", "golang", "-lucky")
+	if code != exitNoResults {
+		t.Errorf("exited with %d, want %d", code, exitNoResults)
+	}
+
+	if !strings.Contains(stderr, `No URL to open for "golang"`) {
+		t.Errorf("stderr is %q", stderr)
+	}
+}
+
+func TestOpenBrowserRejectsSchemes(t *testing.T) {
+	for _, link := range []string{
+		"file:///etc/passwd",
+		"javascript:alert(1)",
+		"smb://attacker.example.com/share",
+		"FILE:///C:/Windows/System32/calc.exe",
+		"//example.com/no-scheme",
+		"https:relative",
+		"not a url\x7f",
+	} {
+		if err := openBrowser(link); err == nil || !strings.HasPrefix(err.Error(), "Refusing to open") {
+			t.Errorf("openBrowser(%q) = %v, want it refused", link, err)
+		}
+	}
+}
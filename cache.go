@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheDirName is the directory under the user's cache directory used when -cache-dir is not set
+const cacheDirName = "duckduckgo-answers"
+
+// canonicalQuery() reduces a preprocessed query to the form used in its cache key: surrounding
+// whitespace is dropped, inner runs of whitespace become a single space and, with
+// -cache-case-insensitive, letters are lowercased. The query that is sent is left as typed
+func canonicalQuery(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+
+	if *flagCacheCaseInsensitive {
+		query = strings.ToLower(query)
+	}
+
+	return query
+}
+
+// cacheKey() identifies the response to a query. It is the API URL that the canonical query would
+// be sent to, so every option that changes the response is part of it: -api-base, the format and
+// HTML flags, -region and -safe. The key is hashed to get a file name that is safe on any system
+func cacheKey(query string, options Options) string {
+	sum := sha256.Sum256([]byte(getAPIURL(canonicalQuery(query), options)))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// cacheDir() returns -cache-dir, or a directory under the user's cache directory by default
+func cacheDir() (string, error) {
+	if *flagCacheDir != "" {
+		return *flagCacheDir, nil
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("Failed to find a cache directory, set -cache-dir: %v", err)
+	}
+
+	return filepath.Join(dir, cacheDirName), nil
+}
+
+// cachePath() returns the file that holds the cached response body for key
+func cachePath(key string) (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, key+".json"), nil
+}
+
+// freshCacheEntry() returns the file of the cache entry for key, if there is one younger than
+// -cache-ttl and -max-cache-age. A missing or expired entry is a miss, the query is then sent as
+// usual and its response replaces the entry
+func freshCacheEntry(key string) (string, bool) {
+	path, err := cachePath(key)
+	if err != nil {
+		logVerbose("%v", err)
+		return "", false
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+
+	age := time.Since(info.ModTime())
+	if *flagCacheTTL > 0 && age > *flagCacheTTL {
+		logVerbose("cache entry %s expired %v ago", path, round(age-*flagCacheTTL))
+		return "", false
+	}
+
+	// -max-cache-age only applies to this run, the entry is refreshed by the query that follows
+	if *flagMaxCacheAge > 0 && age > *flagMaxCacheAge {
+		logVerbose("cache entry %s is %v old, older than -max-cache-age", path, round(age))
+		return "", false
+	}
+
+	return path, true
+}
+
+// readCache() returns the cached response body for key, if freshCacheEntry() finds one. An
+// unreadable entry is a miss as well
+func readCache(key string) (string, bool) {
+	path, ok := freshCacheEntry(key)
+	if !ok {
+		return "", false
+	}
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		logVerbose("failed to read cache entry %s: %v", path, err)
+		return "", false
+	}
+
+	logVerbose("answered from the cache, %s", path)
+	return string(body), true
+}
+
+// cacheWriters bounds the cache entries written at the same time, to -cache-writers. It is
+// created on first use, once the flags have been parsed
+var (
+	cacheWriters     chan struct{}
+	cacheWritersOnce sync.Once
+)
+
+// writeCache() stores a response body under key. The cache is only an optimization, so a failure
+// to write it is logged rather than failing the query
+func writeCache(key string, body string) {
+	path, err := cachePath(key)
+	if err != nil {
+		logVerbose("%v", err)
+		return
+	}
+
+	cacheWritersOnce.Do(func() {
+		writers := *flagCacheWriters
+		if writers < 1 {
+			writers = 1
+		}
+		cacheWriters = make(chan struct{}, writers)
+	})
+
+	cacheWriters <- struct{}{}
+	defer func() { <-cacheWriters }()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		logVerbose("failed to create the cache directory: %v", err)
+		return
+	}
+
+	if err := writeFileAtomic(path, []byte(body)); err != nil {
+		logVerbose("failed to write cache entry %s: %v", path, err)
+	}
+}
+
+// writeFileAtomic() writes data to a temporary file next to path and renames it into place, so
+// that a reader never sees a partly written entry, even when the program is interrupted
+func writeFileAtomic(path string, data []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+
+	if err := os.Chmod(temp.Name(), 0644); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+
+	if err := os.Rename(temp.Name(), path); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestWriteCacheConcurrent writes many entries at once, several of them to the same key, and
+// checks that every entry reads back whole. Run it with -race
+func TestWriteCacheConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	setFlag(t, "cache-dir", dir)
+
+	const keys, writersPerKey = 20, 5
+
+	// A body large enough that a torn write would be noticed
+	body := func(key int, writer int) string {
+		return fmt.Sprintf(`{"AbstractText":"entry %d written by %d %s"}`, key, writer, strings.Repeat("x", 64*1024))
+	}
+
+	var wg sync.WaitGroup
+	for key := 0; key < keys; key++ {
+		for writer := 0; writer < writersPerKey; writer++ {
+			wg.Add(1)
+			go func(key int, writer int) {
+				defer wg.Done()
+
+				writeCache(fmt.Sprintf("key%d", key), body(key, writer))
+			}(key, writer)
+		}
+	}
+	wg.Wait()
+
+	for key := 0; key < keys; key++ {
+		got, ok := readCache(fmt.Sprintf("key%d", key))
+		if !ok {
+			t.Errorf("entry %d is missing", key)
+			continue
+		}
+
+		whole := false
+		for writer := 0; writer < writersPerKey; writer++ {
+			if got == body(key, writer) {
+				whole = true
+			}
+		}
+		if !whole {
+			t.Errorf("entry %d is corrupted, it holds %d bytes starting %.40q", key, len(got), got)
+		}
+	}
+
+	temporary, err := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(temporary) > 0 {
+		t.Errorf("temporary files were left behind: %v", temporary)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != keys {
+		t.Errorf("the cache holds %d files, want %d", len(entries), keys)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	options := defaultOptions()
+	german := defaultOptions()
+	german.Region = "de-de"
+
+	tests := []struct {
+		a, b            string
+		caseInsensitive bool
+		aOptions        Options
+		same            bool
+	}{
+		{"go programming", "  go \t programming ", false, options, true},
+		{"go programming", "Go Programming", false, options, false},
+		{"go programming", "Go  Programming", true, options, true},
+		{"go programming", "go programming", false, german, false},
+		{"go programming", "go programmer", true, options, false},
+	}
+
+	for _, test := range tests {
+		setFlag(t, "cache-case-insensitive", strconv.FormatBool(test.caseInsensitive))
+
+		if same := cacheKey(test.a, test.aOptions) == cacheKey(test.b, options); same != test.same {
+			t.Errorf("cacheKey(%q) == cacheKey(%q) with -cache-case-insensitive=%v is %v, want %v", test.a, test.b, test.caseInsensitive, same, test.same)
+		}
+	}
+}
+
+func TestCacheSharedEntry(t *testing.T) {
+	var requests int32
+	api := stubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		serveJSON(readFixture(t, "golang.json"))(w, r)
+	})
+
+	run := func(args ...string) {
+		t.Helper()
+
+		stdout, stderr, code := runMain(t, "", append([]string{"-api-base", api, "-cache", "-abstract-only"}, args...)...)
+		if code != exitFound || stderr != "" || stdout != "Go is a statically typed, compiled programming language designed at Google.\n" {
+			t.Fatalf("%q exited with %d and printed %q, %q", args, code, stdout, stderr)
+		}
+	}
+
+	// Differently spaced queries share an entry, differently cased ones only when asked to
+	cache := t.TempDir()
+	run("-cache-dir", cache, "-s", "go programming")
+	run("-cache-dir", cache, "-s", "  go   programming ")
+	run("-cache-dir", cache, "-s", "Go Programming")
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("the API was sent %d requests, want 2", got)
+	}
+
+	// Lowercased, the query has the key of the first entry
+	run("-cache-dir", cache, "-cache-case-insensitive", "-s", "GO  PROGRAMMING")
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("the API was sent %d requests, want 2", got)
+	}
+
+	if entries, _ := os.ReadDir(cache); len(entries) != 2 {
+		t.Errorf("the cache holds %d entries, want 2", len(entries))
+	}
+}
+
+func TestMaxCacheAge(t *testing.T) {
+	var requests int32
+	api := stubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		serveJSON(readFixture(t, "golang.json"))(w, r)
+	})
+
+	// -cache-ttl is raised so that only -max-cache-age decides whether the entry is fresh
+	cache := t.TempDir()
+	run := func(maxAge string) string {
+		t.Helper()
+
+		stdout, stderr, code := runMain(t, "", "-api-base", api, "-cache", "-cache-dir", cache, "-cache-ttl", "24h", "-max-cache-age", maxAge, "-abstract-only", "-s", "golang")
+		if code != exitFound || stderr != "" {
+			t.Fatalf("-max-cache-age %s exited with %d: %s", maxAge, code, stderr)
+		}
+		return stdout
+	}
+
+	// A stale entry with a different abstract shows whether the cache was read
+	run("0")
+
+	entries, err := filepath.Glob(filepath.Join(cache, "*.json"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("the cache holds %v, %v", entries, err)
+	}
+	path := entries[0]
+
+	stale := `{"AbstractText":"A stale abstract.","RelatedTopics":[]}`
+	if err := os.WriteFile(path, []byte(stale), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := run("3h"); got != "A stale abstract.\n" || atomic.LoadInt32(&requests) != 1 {
+		t.Errorf("an entry younger than -max-cache-age was not read, got %q", got)
+	}
+
+	if got := run("1h"); got != "Go is a statically typed, compiled programming language designed at Google.\n" || atomic.LoadInt32(&requests) != 2 {
+		t.Errorf("an entry older than -max-cache-age was read, got %q", got)
+	}
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) == stale {
+		t.Error("the entry older than -max-cache-age was not refreshed")
+	}
+
+	// The refreshed entry is young enough for the same run to be answered from the cache
+	run("1h")
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("the API was sent %d requests, want 2", got)
+	}
+
+	if _, stderr, code := runMain(t, "", "-max-cache-age", "soon", "-s", "golang"); code != exitUsage || !strings.Contains(stderr, "-max-cache-age") {
+		t.Errorf("an invalid -max-cache-age exited with %d and printed %q", code, stderr)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// isTerminal() reports whether the file is a character device such as a terminal,
+// as opposed to a pipe or a regular file
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorModes are the accepted values of the -color flag
+var colorModes = []string{"auto", "always", "never"}
+
+// validateColorMode() checks the value of the -color flag
+func validateColorMode(mode string) error {
+	for _, valid := range colorModes {
+		if mode == valid {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Invalid -color %q: expected one of %s", mode, strings.Join(colorModes, ", "))
+}
+
+// colorEnabled() is the single place deciding whether output is colorized. -no-color and
+// -color=never always disable colors and -color=always forces them, even over NO_COLOR or when
+// piped. Otherwise colors are used unless NO_COLOR is set or stdout is redirected
+func colorEnabled() bool {
+	if *flagNoColor {
+		return false
+	}
+
+	switch *flagColor {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	return isTerminal(os.Stdout)
+}
+
+// TrueColors holds 24-bit versions of the TerminalColors, used by terminals that support them
+var TrueColors = map[string]string{
+	"Reset":  "\033[0m",
+	"Red":    trueColor(0xe0, 0x6c, 0x75),
+	"Green":  trueColor(0x98, 0xc3, 0x79),
+	"Blue":   trueColor(0x61, 0xaf, 0xef),
+	"White":  trueColor(0xdc, 0xdf, 0xe4),
+	"Yellow": trueColor(0xe5, 0xc0, 0x7b),
+}
+
+// trueColor() returns the escape sequence setting the foreground to an RGB color
+func trueColor(r, g, b uint8) string {
+	return fmt.Sprintf("\033[38;2;%d;%d;%dm", r, g, b)
+}
+
+// truecolorEnabled() reports whether the TrueColors palette is used. -truecolor decides when it
+// is given, otherwise the palette is used when COLORTERM advertises 24-bit color support
+func truecolorEnabled() bool {
+	if flagIsSet("truecolor") {
+		return *flagTruecolor
+	}
+
+	colorterm := os.Getenv("COLORTERM")
+	return colorterm == "truecolor" || colorterm == "24bit"
+}
+
+// flagIsSet() reports whether the named flag was given on the command line
+func flagIsSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+
+	return set
+}
+
+// palette() returns the color palette supported by the terminal
+func palette() map[string]string {
+	if truecolorEnabled() {
+		return TrueColors
+	}
+
+	return TerminalColors
+}
+
+// color() returns the escape sequence for one of the TerminalColors, or an empty string
+// when colors are disabled
+func color(name string) string {
+	if !colorEnabled() {
+		return ""
+	}
+
+	return palette()[name]
+}
+
+// colorizeJSON() adds terminal colors to JSON text: keys are blue, strings green,
+// numbers and literals red and punctuation white. Whitespace is copied unchanged
+func colorizeJSON(data []byte) []byte {
+	var out bytes.Buffer
+	colors := palette()
+
+	paint := func(name string, token []byte) {
+		out.WriteString(colors[name])
+		out.Write(token)
+		out.WriteString(colors["Reset"])
+	}
+
+	for i := 0; i < len(data); {
+		c := data[i]
+
+		switch {
+		case c == '"':
+			end := jsonStringEnd(data, i)
+
+			// A string followed by a colon is an object key
+			name := "Green"
+			if rest := bytes.TrimLeft(data[end:], " \t\r\n"); len(rest) > 0 && rest[0] == ':' {
+				name = "Blue"
+			}
+
+			paint(name, data[i:end])
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i
+			for end < len(data) && strings.IndexByte("+-0123456789.eE", data[end]) >= 0 {
+				end++
+			}
+
+			paint("Red", data[i:end])
+			i = end
+		case c >= 'a' && c <= 'z':
+			end := i
+			for end < len(data) && data[end] >= 'a' && data[end] <= 'z' {
+				end++
+			}
+
+			paint("Red", data[i:end])
+			i = end
+		case strings.IndexByte("{}[]:,", c) >= 0:
+			paint("White", data[i:i+1])
+			i++
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+
+	return out.Bytes()
+}
+
+// jsonStringEnd() returns the index just past the closing quote of the JSON string starting at start
+func jsonStringEnd(data []byte, start int) int {
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+
+	return len(data)
+}
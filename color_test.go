@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONColors(t *testing.T) {
+	response, err := parseResponse("golang", readFixture(t, "golang.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, formatter := range []JSONFormatter{{}, {Pretty: true}} {
+		setFlag(t, "color", "never")
+
+		var plain bytes.Buffer
+		if err := formatter.Format(&plain, response); err != nil {
+			t.Fatal(err)
+		}
+
+		if !json.Valid(plain.Bytes()) || strings.Contains(plain.String(), "\033[") {
+			t.Errorf("the uncolored output is not plain JSON:\n%s", plain.String())
+		}
+
+		setFlag(t, "color", "always")
+
+		var colored bytes.Buffer
+		if err := formatter.Format(&colored, response); err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(colored.String(), palette()["Blue"]+`"AbstractText"`+palette()["Reset"]) {
+			t.Errorf("the keys are not colored:\n%s", colored.String())
+		}
+
+		// The colors are added around the tokens, nothing else changes
+		if got := stripANSI(colored.String()); got != plain.String() {
+			t.Errorf("without its colors the output is\n%s\nwant\n%s", got, plain.String())
+		}
+	}
+}
+
+func TestColorizeJSONTokens(t *testing.T) {
+	c := palette()
+	got := string(colorizeJSON([]byte(`{"a\"b": [1.5e3, -2, true, null, "x"]}`)))
+
+	want := c["White"] + "{" + c["Reset"] +
+		c["Blue"] + `"a\"b"` + c["Reset"] + c["White"] + ":" + c["Reset"] + " " +
+		c["White"] + "[" + c["Reset"] +
+		c["Red"] + "1.5e3" + c["Reset"] + c["White"] + "," + c["Reset"] + " " +
+		c["Red"] + "-2" + c["Reset"] + c["White"] + "," + c["Reset"] + " " +
+		c["Red"] + "true" + c["Reset"] + c["White"] + "," + c["Reset"] + " " +
+		c["Red"] + "null" + c["Reset"] + c["White"] + "," + c["Reset"] + " " +
+		c["Green"] + `"x"` + c["Reset"] +
+		c["White"] + "]" + c["Reset"] + c["White"] + "}" + c["Reset"]
+
+	if got != want {
+		t.Errorf("colorizeJSON() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestColorMode(t *testing.T) {
+	// The output of the tests is not a terminal, so auto leaves colors off
+	tests := []struct {
+		mode    string
+		noColor string
+		want    bool
+	}{
+		{"auto", "", false},
+		{"auto", "1", false},
+		{"always", "", true},
+		{"always", "1", true},
+		{"never", "", false},
+		{"never", "1", false},
+	}
+
+	for _, test := range tests {
+		setFlag(t, "color", test.mode)
+		setEnv(t, "NO_COLOR", test.noColor)
+
+		if got := colorEnabled(); got != test.want {
+			t.Errorf("colorEnabled() with -color=%s and NO_COLOR=%q = %v, want %v", test.mode, test.noColor, got, test.want)
+		}
+	}
+
+	setFlag(t, "color", "always")
+	setFlag(t, "no-color", "true")
+	if colorEnabled() {
+		t.Error("-no-color did not win over -color=always")
+	}
+}
+
+func TestColorModeOutput(t *testing.T) {
+	api := fixtureServer(t, "golang.json")
+
+	// runMain() sets NO_COLOR, which -color=always overrides
+	for mode, colored := range map[string]bool{"auto": false, "always": true, "never": false} {
+		stdout, stderr, code := runMain(t, "", "-api-base", api, "-color", mode, "golang")
+		if code != exitFound {
+			t.Fatalf("-color=%s exited with %d: %s", mode, code, stderr)
+		}
+
+		if got := strings.Contains(stdout, "\033["); got != colored {
+			t.Errorf("-color=%s printed colors: %v, want %v\n%q", mode, got, colored, stdout)
+		}
+	}
+
+	stdout, stderr, code := runMain(t, "", "-api-base", api, "-color", "sometimes", "golang")
+	if code != exitUsage || stdout != "" {
+		t.Errorf("-color=sometimes exited with %d and printed %q, want %d", code, stdout, exitUsage)
+	}
+
+	if want := `Invalid -color "sometimes": expected one of auto, always, never`; !strings.Contains(stderr, want) {
+		t.Errorf("-color=sometimes printed %q, want %q", stderr, want)
+	}
+}
+
+func TestTruecolor(t *testing.T) {
+	api := fixtureServer(t, "golang.json")
+
+	truecolor, basic := TrueColors["Green"], TerminalColors["Green"]
+
+	tests := []struct {
+		colorterm string
+		args      []string
+		want      string
+	}{
+		{"truecolor", nil, truecolor},
+		{"24bit", nil, truecolor},
+		{"", nil, basic},
+		{"", []string{"-truecolor"}, truecolor},
+		{"truecolor", []string{"-truecolor=false"}, basic},
+	}
+
+	for _, test := range tests {
+		setEnv(t, "COLORTERM", test.colorterm)
+
+		args := append(append([]string{"-api-base", api, "-color", "always"}, test.args...), "golang")
+		stdout, stderr, code := runMain(t, "", args...)
+		if code != exitFound || stderr != "" {
+			t.Fatalf("COLORTERM=%q %q exited with %d and printed %q", test.colorterm, test.args, code, stderr)
+		}
+
+		other := basic
+		if test.want == basic {
+			other = truecolor
+		}
+
+		if !strings.Contains(stdout, test.want) || strings.Contains(stdout, other) {
+			t.Errorf("COLORTERM=%q %q printed the wrong palette:\n%q", test.colorterm, test.args, stdout)
+		}
+	}
+
+	// Without colors the palette does not matter
+	setEnv(t, "COLORTERM", "truecolor")
+	stdout, _, _ := runMain(t, "", "-api-base", api, "-truecolor", "golang")
+	if strings.Contains(stdout, "\033[") {
+		t.Errorf("-truecolor printed colors to a pipe:\n%q", stdout)
+	}
+}
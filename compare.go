@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// regionResult is the outcome of one query of -compare-regions
+type regionResult struct {
+	region   string
+	response Response
+	err      error
+}
+
+// parseRegions() parses the comma separated -compare-regions list
+func parseRegions(value string) ([]string, error) {
+	regions := []string{}
+	for _, code := range strings.Split(value, ",") {
+		region, err := normalizeRegion(code)
+		if err != nil {
+			return nil, err
+		}
+
+		if region != "" {
+			regions = append(regions, region)
+		}
+	}
+
+	if len(regions) < 2 {
+		return nil, fmt.Errorf("Invalid -compare-regions %q: expected at least two regions, e.g. us-en,de-de", value)
+	}
+
+	return regions, nil
+}
+
+// compareRegions() sends the query for every region at once and returns the results in the
+// order the regions were given
+func compareRegions(query string, regions []string, options Options) []regionResult {
+	fetched := fetchAll(len(regions), func(i int) (Response, error) {
+		regionOptions := options
+		regionOptions.Region = regions[i]
+
+		return fetchResponse(query, regionOptions, nil)
+	})
+
+	results := make([]regionResult, len(regions))
+	for i, result := range fetched {
+		results[i] = regionResult{region: regions[i], response: result.response, err: result.err}
+	}
+
+	return results
+}
+
+// printRegionComparison() prints each region's result under a heading, followed by how the
+// abstract of every region compares to that of the first region
+func printRegionComparison(out io.Writer, results []regionResult) error {
+	w := &stickyWriter{w: out}
+
+	for _, result := range results {
+		fmt.Fprintln(w, color("Yellow")+"== "+result.region+" =="+color("Reset"))
+
+		if result.err != nil {
+			fmt.Fprintln(stderr, result.region+":", result.err)
+			continue
+		}
+
+		if err := outputFormatter.Format(w, result.response); err != nil && !isClosedOutput(err) {
+			fmt.Fprintln(stderr, err)
+		}
+	}
+
+	fmt.Fprintln(w, color("Green")+"Abstract differences:"+color("Reset"))
+
+	baseline := results[0]
+	for _, result := range results {
+		fmt.Fprintf(w, "%s%s: %s\n", indent(), result.region, abstractStatus(result, baseline))
+	}
+
+	return w.err
+}
+
+// abstractStatus() describes the abstract of a result compared to the baseline result:
+// present or absent for the baseline itself, otherwise same, differs or absent
+func abstractStatus(result regionResult, baseline regionResult) string {
+	switch {
+	case result.err != nil:
+		return "failed"
+	case result.response.AbstractText == "":
+		return "absent"
+	case result.region == baseline.region:
+		return "present"
+	case baseline.err == nil && result.response.AbstractText == baseline.response.AbstractText:
+		return "same as " + baseline.region
+	}
+
+	return "differs from " + baseline.region
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCompareRegions(t *testing.T) {
+	golang := readFixture(t, "golang.json")
+	german := strings.Replace(golang, "Go is a statically typed", "Go ist eine statisch typisierte", 1)
+
+	api := stubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("kl") {
+		case "de-de":
+			serveJSON(german)(w, r)
+		case "ch-fr":
+			serveJSON(readFixture(t, "define.json"))(w, r)
+		default:
+			serveJSON(golang)(w, r)
+		}
+	})
+
+	stdout, stderr, code := runMain(t, "", "-api-base", api, "-abstract-only", "-compare-regions", "US-EN, de-de,uk-en,ch-fr", "-s", "golang")
+	if code != exitFound || stderr != "" {
+		t.Fatalf("-compare-regions exited with %d and printed %q", code, stderr)
+	}
+
+	// Every region is printed under its heading in the order given, then the differences
+	want := strings.Join([]string{
+		"== us-en ==",
+		"Go is a statically typed, compiled programming language designed at Google.",
+		"== de-de ==",
+		"Go ist eine statisch typisierte, compiled programming language designed at Google.",
+		"== uk-en ==",
+		"Go is a statically typed, compiled programming language designed at Google.",
+		"== ch-fr ==",
+		"Abstract differences:",
+		"\tus-en: present",
+		"\tde-de: differs from us-en",
+		"\tuk-en: same as us-en",
+		"\tch-fr: absent",
+	}, "\n") + "\n"
+
+	if stdout != want {
+		t.Errorf("-compare-regions printed\n%s\nwant\n%s", stdout, want)
+	}
+}
+
+func TestCompareRegionsInvalid(t *testing.T) {
+	tests := map[string]string{
+		"us-en":          `Invalid -compare-regions "us-en": expected at least two regions, e.g. us-en,de-de`,
+		"us-en,,":        `Invalid -compare-regions "us-en,,": expected at least two regions, e.g. us-en,de-de`,
+		"us-en,germany":  `Invalid region "germany"`,
+		"us-en,de-de,12": `Invalid region "12"`,
+	}
+
+	for value, want := range tests {
+		stdout, stderr, code := runMain(t, "", "-api-base", closedAPI(t), "-compare-regions", value, "-s", "golang")
+		if code != exitUsage || stdout != "" || !strings.Contains(stderr, want) {
+			t.Errorf("-compare-regions %q exited with %d and printed %q, %q, want %d and %q", value, code, stdout, stderr, exitUsage, want)
+		}
+	}
+}
+
+func TestCompareRegionsFailed(t *testing.T) {
+	api := stubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("kl") == "de-de" {
+			http.Error(w, "unavailable", http.StatusNotFound)
+			return
+		}
+
+		serveJSON(readFixture(t, "golang.json"))(w, r)
+	})
+
+	stdout, stderr, code := runMain(t, "", "-api-base", api, "-abstract-only", "-compare-regions", "us-en,de-de", "-s", "golang")
+	if code != exitError {
+		t.Errorf("-compare-regions with a failed region exited with %d, want %d", code, exitError)
+	}
+
+	if !strings.HasPrefix(stderr, "de-de: ") || !strings.Contains(stdout, "\tde-de: failed\n") || !strings.Contains(stdout, "\tus-en: present\n") {
+		t.Errorf("-compare-regions with a failed region printed\n%s\n%s", stdout, stderr)
+	}
+}
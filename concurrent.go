@@ -0,0 +1,37 @@
+package main
+
+import "sync"
+
+// fetchResult is the outcome of one of the queries sent by fetchAll()
+type fetchResult struct {
+	response Response
+	err      error
+}
+
+// fetchAll() runs fetch for each index from 0 to count at once, returning the results in
+// index order once every one has finished
+func fetchAll(count int, fetch func(i int) (Response, error)) []fetchResult {
+	results := make([]fetchResult, count)
+
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			results[i] = fetchGuarded(fetch, i)
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// fetchGuarded() calls fetch, turning a panic into the error of its result, as a panic in a
+// goroutine could not otherwise be recovered from
+func fetchGuarded(fetch func(i int) (Response, error), i int) (result fetchResult) {
+	defer recoverPanic(&result.err)
+
+	result.response, result.err = fetch(i)
+	return result
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+)
+
+// authHeaders are the headers that carry credentials, -debug-dump points them out so that they
+// can be removed before a dump is shared
+var authHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// debugSection() starts a section of the -debug-dump block
+func debugSection(w io.Writer, title string) {
+	fmt.Fprintf(w, "--- %s ---\n", title)
+}
+
+// beginDebugDump() starts the -debug-dump block of a query with the options it is sent with.
+// The returned function ends the block, it is deferred so that the block is closed on errors too
+func beginDebugDump(w io.Writer, query string, options Options, apiURL string) func() {
+	fmt.Fprintf(w, "===== BEGIN debug dump of %q =====\n", query)
+
+	debugSection(w, "Options")
+	fmt.Fprintf(w, "%+v\n", options)
+	flag.Visit(func(f *flag.Flag) {
+		fmt.Fprintf(w, "-%s=%s\n", f.Name, f.Value)
+	})
+
+	debugSection(w, "URL")
+	fmt.Fprintln(w, apiURL)
+
+	return func() {
+		fmt.Fprintf(w, "===== END debug dump of %q =====\n", query)
+	}
+}
+
+// dumpParsed() writes the parsed response to the -debug-dump block
+func dumpParsed(w io.Writer, response Response) {
+	debugSection(w, "Parsed response")
+
+	data, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		fmt.Fprintf(w, "%+v\n", response)
+		return
+	}
+
+	fmt.Fprintf(w, "%s\n", data)
+}
+
+// debugDumpTransport is an http.RoundTripper that writes every request and response passing
+// through it, headers and body included, to the -debug-dump block. Nothing is redacted
+type debugDumpTransport struct {
+	next http.RoundTripper
+	w    io.Writer
+}
+
+func (t debugDumpTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	// Prefetches run in the background while the user is at the prompt, they are not dumped
+	if isPrefetch(request.Context()) {
+		return t.next.RoundTrip(request)
+	}
+
+	debugSection(t.w, "Request")
+	fmt.Fprintf(t.w, "%s %s\n", request.Method, request.URL)
+	dumpHeaders(t.w, request.Header)
+
+	for _, name := range authHeaders {
+		if request.Header.Get(name) != "" {
+			fmt.Fprintf(t.w, "Note: the request carries credentials in %s, remove them before sharing this dump\n", name)
+		}
+	}
+
+	response, err := t.next.RoundTrip(request)
+
+	debugSection(t.w, "Response")
+	if err != nil {
+		fmt.Fprintf(t.w, "Error: %v\n", err)
+		return nil, err
+	}
+
+	fmt.Fprintf(t.w, "%s %s\n", response.Proto, response.Status)
+	dumpHeaders(t.w, response.Header)
+
+	// Read the body here so that it can be dumped, and hand a copy of it on
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	response.Body = io.NopCloser(bytes.NewReader(body))
+
+	debugSection(t.w, "Body")
+	t.w.Write(body)
+	if !bytes.HasSuffix(body, []byte("\n")) {
+		fmt.Fprintln(t.w)
+	}
+	if err != nil {
+		fmt.Fprintf(t.w, "Error: %v\n", err)
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// dumpHeaders() writes headers one per line, sorted by name
+func dumpHeaders(w io.Writer, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range header[name] {
+			fmt.Fprintf(w, "%s: %s\n", name, value)
+		}
+	}
+}
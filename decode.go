@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The Instant Answer API does not keep a consistent schema, several fields are a string for
+// some queries and an object, number or array for others. The types in this file decode those
+// fields defensively, degrading a value of the wrong type to empty instead of failing the whole parse
+
+// FlexString is a string field that may be sent as another JSON type
+type FlexString string
+
+func (s *FlexString) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		logCoercion("string", data)
+		*s = ""
+		return nil
+	}
+
+	*s = FlexString(value)
+	return nil
+}
+
+// FlexInt is a number field that may be sent as a string, e.g. "300", or as an empty string
+// when the value is unknown
+type FlexInt int
+
+func (n *FlexInt) UnmarshalJSON(data []byte) error {
+	var value int
+	if err := json.Unmarshal(data, &value); err == nil {
+		*n = FlexInt(value)
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		if parsed, err := strconv.Atoi(strings.TrimSpace(text)); err == nil || text == "" {
+			*n = FlexInt(parsed)
+			return nil
+		}
+	}
+
+	logCoercion("number", data)
+	*n = 0
+	return nil
+}
+
+// Infobox holds the structured facts about the subject of a query, the API sends
+// an empty string in its place when there are none
+type Infobox struct {
+	Content []InfoboxEntry `json:"content,omitempty"`
+	Meta    []InfoboxEntry `json:"meta,omitempty"`
+}
+
+// InfoboxEntry is a single labelled fact, e.g. "Designed by": "Robert Griesemer"
+type InfoboxEntry struct {
+	DataType FlexString `json:"data_type"`
+	Label    FlexString `json:"label"`
+	Value    FlexString `json:"value"`
+}
+
+func (i *Infobox) UnmarshalJSON(data []byte) error {
+	// infobox has the same fields but not the UnmarshalJSON method, avoiding infinite recursion
+	type infobox Infobox
+
+	var value infobox
+	if err := json.Unmarshal(data, &value); err != nil {
+		// An empty string is how the API says there is no infobox, so it is not worth a warning
+		if string(bytes.TrimSpace(data)) != `""` {
+			logCoercion("object", data)
+		}
+		*i = Infobox{}
+		return nil
+	}
+
+	*i = Infobox(value)
+	return nil
+}
+
+// logCoercion() notes in verbose mode that a value was not of the expected type and was discarded
+func logCoercion(expected string, data []byte) {
+	logVerbose("expected a JSON %s but got %s %s, treating it as empty", expected, jsonKind(data), truncate(string(data), 60))
+}
+
+// jsonKind() names the type of a raw JSON value from its first character
+func jsonKind(data []byte) string {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return "nothing"
+	}
+
+	switch data[0] {
+	case '{':
+		return "an object"
+	case '[':
+		return "an array"
+	case '"':
+		return "a string"
+	case 't', 'f':
+		return "a boolean"
+	case 'n':
+		return "null"
+	default:
+		return "a number"
+	}
+}
+
+// truncate() shortens s to at most max characters, marking the cut with "..."
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+
+	return string(runes[:max]) + "..."
+}
+
+// unknownFields() reports the parts of a response that the Response struct does not model.
+// Each top level field is decoded on its own with DisallowUnknownFields, so every unmodeled
+// field is listed rather than only the first, along with the first unmodeled field nested inside
+// of each modeled one. It returns nil when every field is known
+func unknownFields(data []byte) ([]string, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	unknown := []string{}
+	for _, name := range names {
+		field, err := json.Marshal(map[string]json.RawMessage{name: fields[name]})
+		if err != nil {
+			return nil, err
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(field))
+		decoder.DisallowUnknownFields()
+
+		err = decoder.Decode(&Response{})
+		if err == nil || !strings.HasPrefix(err.Error(), "json: unknown field ") {
+			continue
+		}
+
+		// The error names the unknown field in quotes, e.g. json: unknown field "Icon"
+		unknownName := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		if unknownName == strconv.Quote(name) {
+			unknown = append(unknown, unknownName)
+		} else {
+			unknown = append(unknown, fmt.Sprintf("%s inside %q", unknownName, name))
+		}
+	}
+
+	if len(unknown) == 0 {
+		return nil, nil
+	}
+
+	return unknown, nil
+}
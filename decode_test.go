@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFlexibleFields(t *testing.T) {
+	setFlag(t, "v", "true")
+
+	var diagnostics bytes.Buffer
+	previous := stderr
+	stderr = &diagnostics
+	t.Cleanup(func() { stderr = previous })
+
+	t.Run("answer object", func(t *testing.T) {
+		diagnostics.Reset()
+
+		response, err := parseResponse("1+1", readFixture(t, "answer-object.json"), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.Answer != "" || response.AnswerType != "" {
+			t.Errorf("the objects in Answer and AnswerType were kept as %q and %q", response.Answer, response.AnswerType)
+		}
+
+		if !reflect.DeepEqual(response.Infobox, Infobox{}) {
+			t.Errorf("the array in Infobox was kept as %+v", response.Infobox)
+		}
+
+		// Numbers sent as strings are converted, or empty when the string is
+		if response.ImageWidth != 300 || response.ImageHeight != 0 || response.ImageIsLogo != 1 {
+			t.Errorf("the image is %dx%d, logo %d, want 300x0, logo 1", response.ImageWidth, response.ImageHeight, response.ImageIsLogo)
+		}
+
+		for _, kind := range []string{"expected a JSON string but got an object", "expected a JSON object but got an array"} {
+			if !strings.Contains(diagnostics.String(), kind) {
+				t.Errorf("the coercion %q was not logged:\n%s", kind, diagnostics.String())
+			}
+		}
+	})
+
+	t.Run("infobox object", func(t *testing.T) {
+		diagnostics.Reset()
+
+		response, err := parseResponse("robert griesemer", readFixture(t, "infobox.json"), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.Answer != "co-designer of Go" {
+			t.Errorf("Answer is %q", response.Answer)
+		}
+
+		want := Infobox{
+			Content: []InfoboxEntry{
+				{DataType: "string", Label: "Known for", Value: "Go, V8, Sawzall"},
+				{DataType: "wikidata_id", Label: "Wikidata id", Value: ""},
+			},
+			Meta: []InfoboxEntry{{DataType: "string", Label: "article_title", Value: "Robert Griesemer"}},
+		}
+		if !reflect.DeepEqual(response.Infobox, want) {
+			t.Errorf("Infobox is %+v, want %+v", response.Infobox, want)
+		}
+
+		var out bytes.Buffer
+		if err := printResponse(&out, response); err != nil {
+			t.Fatal(err)
+		}
+		for _, text := range []string{"Robert Griesemer [person]", "Answer:", "co-designer of Go", "Robert Griesemer is a Swiss computer scientist."} {
+			if !strings.Contains(out.String(), text) {
+				t.Errorf("the output has no %q:\n%s", text, out.String())
+			}
+		}
+	})
+
+	t.Run("empty infobox string", func(t *testing.T) {
+		diagnostics.Reset()
+
+		if _, err := parseResponse("golang", readFixture(t, "golang.json"), false); err != nil {
+			t.Fatal(err)
+		}
+
+		if diagnostics.Len() > 0 {
+			t.Errorf("the empty string Infobox was logged as a coercion:\n%s", diagnostics.String())
+		}
+	})
+}
+
+func TestStrict(t *testing.T) {
+	api := fixtureServer(t, "unmodeled.json")
+	want := []string{`"Badge" inside "RelatedTopics"`, `"Spice"`}
+
+	unknown, err := unknownFields([]byte(readFixture(t, "unmodeled.json")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(unknown, want) {
+		t.Errorf("unknownFields() = %q", unknown)
+	}
+
+	if unknown, err := unknownFields([]byte(readFixture(t, "meta.json"))); err != nil || unknown != nil {
+		t.Errorf("unknownFields() on meta.json = %q, %v, want nil", unknown, err)
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		stdout, stderr, code := runMain(t, "", "-api-base", api, "-strict", "-v", "rust")
+		if code != exitFound {
+			t.Fatalf("-strict exited with %d, want %d: %s", code, exitFound, stderr)
+		}
+
+		for _, field := range want {
+			if !strings.Contains(stderr, "unmodeled field in response, "+field) {
+				t.Errorf("-strict -v did not report %s:\n%s", field, stderr)
+			}
+		}
+
+		if !strings.Contains(stdout, "Rust is a general-purpose programming language.") {
+			t.Errorf("-strict did not print the response:\n%s", stdout)
+		}
+	})
+
+	t.Run("strict without verbose", func(t *testing.T) {
+		_, stderr, code := runMain(t, "", "-api-base", api, "-strict", "rust")
+		if code != exitFound || strings.Contains(stderr, "unmodeled") {
+			t.Errorf("-strict without -v exited with %d and printed %q", code, stderr)
+		}
+	})
+
+	t.Run("strict-fail", func(t *testing.T) {
+		stdout, stderr, code := runMain(t, "", "-api-base", api, "-strict-fail", "rust")
+		if code != exitError {
+			t.Errorf("-strict-fail exited with %d, want %d", code, exitError)
+		}
+
+		if !strings.Contains(stderr, "Response contains 2 unmodeled field(s)") {
+			t.Errorf("-strict-fail printed %q", stderr)
+		}
+
+		if strings.Contains(stdout, "Rust") {
+			t.Errorf("-strict-fail printed the response:\n%s", stdout)
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// definitionQuery() phrases a word as a query that the API answers with its definition
+func definitionQuery(word string) string {
+	return "define " + strings.Join(strings.Fields(word), " ")
+}
+
+// lookupDefinition() searches for the definition of a word, for -define
+func lookupDefinition(word string, options Options) (Response, error) {
+	return fetchResponse(definitionQuery(word), options, nil)
+}
+
+// printDefinition() prints only the definition of a response, as a dictionary entry: the word,
+// the definition and the dictionary it comes from
+func printDefinition(w io.Writer, word string, input Response) error {
+	out := &stickyWriter{w: w}
+
+	fmt.Fprintln(out, color("Green")+strings.TrimSpace(word)+color("Reset"))
+	fmt.Fprintln(out, color("White")+indent()+input.Definition+color("Reset"))
+
+	if input.DefinitionSource != "" {
+		fmt.Fprintln(out, color("White")+indent()+"— "+input.DefinitionSource+color("Reset"))
+	}
+
+	if input.DefinitionURL != "" {
+		fmt.Fprintln(out, color("Blue")+indent()+input.DefinitionURL+color("Reset"))
+	}
+
+	return out.err
+}
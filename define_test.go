@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestDefine(t *testing.T) {
+	setFlag(t, "color", "never")
+
+	definition := readFixture(t, "define.json")
+	apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") == "define monad" {
+			serveJSON(definition)(w, r)
+			return
+		}
+		serveJSON(`{"AbstractText":"","RelatedTopics":[]}`)(w, r)
+	})
+
+	response, err := lookupDefinition("  monad ", defaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := printDefinition(&out, "  monad ", response); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "monad\n" +
+		"\tmonad definition: A single unit; a one.\n" +
+		"\t— Merriam-Webster\n" +
+		"\thttps://www.merriam-webster.com/dictionary/monad\n"
+	if out.String() != want {
+		t.Errorf("printed\n%s\nwant\n%s", out.String(), want)
+	}
+
+	// A word without a definition finds nothing to print
+	response, err = lookupDefinition("xyzzy", defaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Definition != "" {
+		t.Errorf("found the definition %q for a word without one", response.Definition)
+	}
+}
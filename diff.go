@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// diffQueries() sends both queries at once and returns their results in order
+func diffQueries(queryA string, queryB string, options Options) (fetchResult, fetchResult) {
+	queries := []string{queryA, queryB}
+
+	results := fetchAll(len(queries), func(i int) (Response, error) {
+		return fetchResponse(queries[i], options, nil)
+	})
+
+	return results[0], results[1]
+}
+
+// printDiff() prints how the result of b differs from that of a in the style of a unified diff:
+// lines only in a start with "-", lines only in b with "+" and shared lines with a space.
+// Related topics are matched by URL, or by text for topics without one
+func printDiff(out io.Writer, a Response, b Response) error {
+	w := &stickyWriter{w: out}
+
+	fmt.Fprintln(w, color("Red")+"--- "+a.Query+color("Reset"))
+	fmt.Fprintln(w, color("Green")+"+++ "+b.Query+color("Reset"))
+
+	fmt.Fprintln(w, "@@ Abstract @@")
+	if a.AbstractText == b.AbstractText {
+		if a.AbstractText != "" {
+			fmt.Fprintln(w, " "+a.AbstractText)
+		}
+	} else {
+		if a.AbstractText != "" {
+			fmt.Fprintln(w, color("Red")+"-"+a.AbstractText+color("Reset"))
+		}
+		if b.AbstractText != "" {
+			fmt.Fprintln(w, color("Green")+"+"+b.AbstractText+color("Reset"))
+		}
+	}
+
+	fmt.Fprintln(w, "@@ Related topics @@")
+
+	topicsA, topicsB := flattenTopics(a.RelatedTopics), flattenTopics(b.RelatedTopics)
+
+	inB := map[string]bool{}
+	for _, topic := range topicsB {
+		inB[topicKey(topic)] = true
+	}
+
+	inA := map[string]bool{}
+	for _, topic := range topicsA {
+		inA[topicKey(topic)] = true
+
+		if inB[topicKey(topic)] {
+			fmt.Fprintln(w, " "+topicLabel(topic))
+		} else {
+			fmt.Fprintln(w, color("Red")+"-"+topicLabel(topic)+color("Reset"))
+		}
+	}
+
+	for _, topic := range topicsB {
+		if !inA[topicKey(topic)] {
+			fmt.Fprintln(w, color("Green")+"+"+topicLabel(topic)+color("Reset"))
+		}
+	}
+
+	return w.err
+}
+
+// topicKey() identifies a related topic when comparing results
+func topicKey(topic RelatedTopic) string {
+	if topic.FirstURL != "" {
+		return topic.FirstURL
+	}
+
+	return topic.Text
+}
+
+// topicLabel() is the line printed for a related topic in a diff, e.g. "Rob Pike (https://duckduckgo.com/Rob_Pike)"
+func topicLabel(topic RelatedTopic) string {
+	switch {
+	case topic.Text == "":
+		return topic.FirstURL
+	case topic.FirstURL == "":
+		return topic.Text
+	}
+
+	return topic.Text + " (" + topic.FirstURL + ")"
+}
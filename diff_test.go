@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	golang := readFixture(t, "golang.json")
+	griesemer := strings.NewReplacer(
+		"Ken_Thompson", "Robert_Griesemer",
+		"Ken Thompson American pioneer of computer science", "Robert Griesemer Swiss computer scientist",
+	).Replace(golang)
+
+	api := stubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("q") {
+		case "golang":
+			serveJSON(golang)(w, r)
+		case "go language":
+			serveJSON(griesemer)(w, r)
+		default:
+			serveJSON(readFixture(t, "define.json"))(w, r)
+		}
+	})
+
+	stdout, stderr, code := runMain(t, "", "-api-base", api, "-diff", "golang", "go language")
+	if code != exitFound || stderr != "" {
+		t.Fatalf("-diff exited with %d and printed %q", code, stderr)
+	}
+
+	want := strings.Join([]string{
+		"--- golang",
+		"+++ go language",
+		"@@ Abstract @@",
+		" Go is a statically typed, compiled programming language designed at Google.",
+		"@@ Related topics @@",
+		" Rob Pike Canadian programmer (https://duckduckgo.com/Rob_Pike)",
+		"-Ken Thompson American pioneer of computer science (https://duckduckgo.com/Ken_Thompson)",
+		" C (programming language) general-purpose (https://duckduckgo.com/C_(programming_language))",
+		" Limbo (https://en.wikipedia.org/wiki/Limbo)",
+		"+Robert Griesemer Swiss computer scientist (https://duckduckgo.com/Robert_Griesemer)",
+	}, "\n") + "\n"
+
+	if stdout != want {
+		t.Errorf("-diff printed\n%s\nwant\n%s", stdout, want)
+	}
+
+	// A changed abstract is a removed and an added line
+	stdout, _, code = runMain(t, "", "-api-base", api, "-diff", "golang", "monad")
+	if code != exitFound || !strings.Contains(stdout, "@@ Abstract @@\n-Go is a statically typed") || !strings.Contains(stdout, "\n-Rob Pike Canadian programmer") {
+		t.Errorf("-diff golang monad exited with %d and printed\n%s", code, stdout)
+	}
+}
+
+func TestDiffInvalid(t *testing.T) {
+	for _, args := range [][]string{{"golang"}, {"golang", "go", "language"}} {
+		stdout, stderr, code := runMain(t, "", append([]string{"-api-base", closedAPI(t), "-diff"}, args...)...)
+		if code != exitUsage || stdout != "" || !strings.Contains(stderr, "Invalid -diff: expected two queries") {
+			t.Errorf("-diff %q exited with %d and printed %q, %q, want %d", args, code, stdout, stderr, exitUsage)
+		}
+	}
+
+	_, stderr, code := runMain(t, "", "-api-base", closedAPI(t), "-diff", "golang", "go language")
+	if code != exitError || stderr == "" {
+		t.Errorf("-diff with the API down exited with %d and printed %q, want %d", code, stderr, exitError)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// disambiguationType is the value of Response.Type for queries with several possible meanings
+const disambiguationType = "D"
+
+// isDisambiguation() reports whether the response lists candidate topics rather than an answer
+func isDisambiguation(input Response) bool {
+	return input.Type == disambiguationType
+}
+
+// topicQuery() derives a search query for a related topic from the final path segment of its
+// FirstURL, e.g. https://duckduckgo.com/Apple_Inc. becomes "Apple Inc.", falling back to its text
+func topicQuery(topic RelatedTopic) string {
+	if parsedURL, err := url.Parse(topic.FirstURL); err == nil {
+		if name := path.Base(parsedURL.Path); name != "." && name != "/" {
+			return strings.ReplaceAll(name, "_", " ")
+		}
+	}
+
+	return topic.Text
+}
+
+// printDisambiguation() prints a numbered list of the candidate topics of an ambiguous query
+func printDisambiguation(w io.Writer, input Response) {
+	fmt.Fprintf(w, "\n %q may refer to: \n \n", input.Query)
+
+	number := 1
+	for _, topic := range input.RelatedTopics {
+		if len(topic.Topics) > 0 {
+			fmt.Fprintln(w, color("Green"), topic.Name+":")
+		}
+
+		for _, candidate := range flattenTopics([]RelatedTopic{topic}) {
+			fmt.Fprintln(w, color("Blue"), fmt.Sprintf("%s%d. %s", indent(), number, candidate.FirstURL))
+			fmt.Fprintln(w, color("White"), indent()+"   "+candidate.Text+blank())
+			number++
+		}
+	}
+
+	fmt.Fprint(w, color("Reset"))
+}
+
+// disambiguationPrompt() asks the user to pick one of the candidate topics by number,
+// returning nil when they press enter without choosing one
+func disambiguationPrompt(input Response) (*RelatedTopic, error) {
+	candidates := flattenTopics(input.RelatedTopics)
+
+	fmt.Fprintf(os.Stderr, "Pick a topic number (1-%d), or press enter to skip: ", len(candidates))
+
+	line, err := stdinReader.readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil, nil
+	}
+
+	number, err := strconv.Atoi(line)
+	if err != nil || number < 1 || number > len(candidates) {
+		return nil, fmt.Errorf("Invalid topic number %q", line)
+	}
+
+	return &candidates[number-1], nil
+}
+
+// drillDownDisambiguation() keeps offering the candidates of ambiguous responses and re-querying
+// with the chosen topic until the user skips or an unambiguous answer is reached, returning the last response
+func drillDownDisambiguation(input Response, options Options) Response {
+	for isDisambiguation(input) && len(input.RelatedTopics) > 0 {
+		choice, err := disambiguationPrompt(input)
+		if err == io.EOF {
+			return input
+		}
+
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			continue
+		}
+
+		if choice == nil {
+			return input
+		}
+
+		response, err := processAPIRequest(topicQuery(*choice), options)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return input
+		}
+
+		input = response
+	}
+
+	return input
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// noDomain heads the topics whose URL is missing or cannot be parsed
+const noDomain = "(no domain)"
+
+// domainGroup holds the related topics linking to one host
+type domainGroup struct {
+	host   string
+	topics []RelatedTopic
+}
+
+// groupByDomain() groups topics by the host of their URL, the largest group first and groups
+// of the same size by host name. Categories are flattened, as a domain cuts across them
+func groupByDomain(topics []RelatedTopic) []domainGroup {
+	index := map[string]int{}
+	groups := []domainGroup{}
+
+	for _, topic := range flattenTopics(topics) {
+		host := topicHost(topic)
+
+		i, ok := index[host]
+		if !ok {
+			i = len(groups)
+			index[host] = i
+			groups = append(groups, domainGroup{host: host})
+		}
+
+		groups[i].topics = append(groups[i].topics, topic)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if len(groups[i].topics) != len(groups[j].topics) {
+			return len(groups[i].topics) > len(groups[j].topics)
+		}
+		return groups[i].host < groups[j].host
+	})
+
+	return groups
+}
+
+// topicHost() returns the lowercased host of a topic's URL, or noDomain
+func topicHost(topic RelatedTopic) string {
+	parsed, err := url.Parse(topic.FirstURL)
+	if err != nil || parsed.Hostname() == "" {
+		return noDomain
+	}
+
+	return strings.ToLower(parsed.Hostname())
+}
+
+// printTopicsByDomain() prints the related topics in a section per domain, for -group-by-domain
+func printTopicsByDomain(w io.Writer, topics []RelatedTopic) {
+	fmt.Fprintln(w, color("Green"), "Related topics by domain: ")
+
+	width := numberWidth(topics)
+
+	for _, group := range groupByDomain(topics) {
+		fmt.Fprintln(w, color("Green"), fmt.Sprintf("%s%s (%d):", indent(), group.host, len(group.topics)))
+
+		for _, topic := range group.topics {
+			label, padding := numberLabel(topic, width)
+			fmt.Fprintln(w, color("Blue"), indent()+label+topic.FirstURL)
+			fmt.Fprintln(w, color("White"), indent()+padding+topic.Text+blank())
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGroupByDomain(t *testing.T) {
+	response, err := parseResponse("git", readFixture(t, "domains.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The largest group comes first, groups of the same size by host name
+	want := []struct {
+		host  string
+		texts []string
+	}{
+		{"en.wikipedia.org", []string{"Mercurial", "GitLab", "Bitbucket"}},
+		{noDomain, []string{"Broken link", "No link"}},
+		{"duckduckgo.com", []string{"Linus Torvalds", "Gitea"}},
+		{"github.com", []string{"git/git"}},
+	}
+
+	groups := groupByDomain(response.RelatedTopics)
+	if len(groups) != len(want) {
+		t.Fatalf("groupByDomain() returned %d groups, want %d", len(groups), len(want))
+	}
+
+	for i, group := range groups {
+		texts := []string{}
+		for _, topic := range group.topics {
+			texts = append(texts, topic.Text)
+		}
+
+		if group.host != want[i].host || !reflect.DeepEqual(texts, want[i].texts) {
+			t.Errorf("group %d is %s %q, want %s %q", i+1, group.host, texts, want[i].host, want[i].texts)
+		}
+	}
+}
+
+func TestGroupByDomainOutput(t *testing.T) {
+	stdout, stderr, code := runMain(t, "", "-api-base", fixtureServer(t, "domains.json"), "-group-by-domain", "git")
+	if code != exitFound || stderr != "" {
+		t.Fatalf("-group-by-domain exited with %d and printed %q", code, stderr)
+	}
+
+	headings := []string{
+		"Related topics by domain:",
+		"\ten.wikipedia.org (3):",
+		"\t(no domain) (2):",
+		"\tduckduckgo.com (2):",
+		"\tgithub.com (1):",
+	}
+
+	previous := -1
+	for _, heading := range headings {
+		at := strings.Index(stdout, heading)
+		if at <= previous {
+			t.Errorf("-group-by-domain printed %q out of order or not at all:\n%s", heading, stdout)
+		}
+		previous = at
+	}
+
+	// The categories are flattened into the domains
+	if strings.Contains(stdout, "Hosting") {
+		t.Errorf("-group-by-domain printed a category:\n%s", stdout)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+// Exit codes of the program, so that scripts can tell "found nothing" apart from a failure.
+// flag.Parse() already exits with exitUsage when given an unknown flag
+const (
+	exitFound     = 0 // the query succeeded and found something
+	exitError     = 1 // the query failed, e.g. the API could not be reached
+	exitUsage     = 2 // the command line was invalid
+	exitNoResults = 3 // the query succeeded, but found nothing
+)
+
+// searchExitCode() returns the exit code for the result of -s. A result counts as found unless
+// isEmptyResult(), or for -abstract-only and -select only when the part that was asked for exists
+func searchExitCode(response Response) int {
+	found := !isEmptyResult(response)
+
+	switch {
+	case *flagAbstractOnly:
+		found = response.AbstractText != ""
+	case *flagSelect > 0:
+		_, err := selectedURL(response, *flagSelect)
+		found = err == nil
+	}
+
+	if !found {
+		return exitNoResults
+	}
+
+	return exitFound
+}
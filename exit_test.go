@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFailOnEmpty(t *testing.T) {
+	api := stubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") == "golang" {
+			serveJSON(readFixture(t, "golang.json"))(w, r)
+			return
+		}
+
+		serveJSON(readFixture(t, "empty.json"))(w, r)
+	})
+
+	tests := []struct {
+		input  string
+		args   []string
+		code   int
+		stderr string
+	}{
+		{"", []string{"-fail-on-empty", "-s", "xyzzy"}, exitError, "No results for \"xyzzy\"\n"},
+		{"", []string{"-s", "xyzzy"}, exitNoResults, ""},
+		{"", []string{"-fail-on-empty", "-s", "golang"}, exitFound, ""},
+		// A batch fails when any of its queries found nothing
+		{"golang\nxyzzy\nplugh\n", []string{"-fail-on-empty"}, exitError, "No results for 2 queries\n"},
+		{"golang\nxyzzy\n", nil, exitFound, ""},
+		{"golang\n", []string{"-fail-on-empty"}, exitFound, ""},
+	}
+
+	for _, test := range tests {
+		_, stderr, code := runMain(t, test.input, append([]string{"-api-base", api, "-abstract-only", "-empty-message", ""}, test.args...)...)
+		if code != test.code || stderr != test.stderr {
+			t.Errorf("%q with %q as input exited with %d and printed %q, want %d and %q", test.args, test.input, code, stderr, test.code, test.stderr)
+		}
+	}
+
+	_, stderr, code := runMain(t, "", "-fail-on-empty=sometimes", "-s", "xyzzy")
+	if code != exitUsage || !strings.Contains(stderr, "invalid boolean value") {
+		t.Errorf("-fail-on-empty=sometimes exited with %d and printed %q, want %d", code, stderr, exitUsage)
+	}
+}
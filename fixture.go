@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// saveFixture() writes a raw API response body to path, indented so that it reads well as a
+// test fixture. A body that is not valid JSON is written exactly as it was received
+func saveFixture(path string, body []byte) error {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", "  "); err == nil {
+		indented.WriteByte('\n')
+		body = indented.Bytes()
+	}
+
+	if err := os.WriteFile(path, body, 0644); err != nil {
+		return fmt.Errorf("Failed to save -save-fixture: %v", err)
+	}
+
+	logVerbose("saved the response body to %s", path)
+	return nil
+}
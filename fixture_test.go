@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveFixture(t *testing.T) {
+	fixture := readFixture(t, "golang.json")
+
+	// The API sends compact JSON, it is saved indented like the fixtures under testdata
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(fixture)); err != nil {
+		t.Fatal(err)
+	}
+	api := stubAPI(t, serveJSON(compact.String()))
+
+	path := filepath.Join(t.TempDir(), "golang.json")
+	saved, stderr, code := runMain(t, "", "-api-base", api, "-save-fixture", path, "golang")
+	if code != exitFound || stderr != "" {
+		t.Fatalf("-save-fixture exited with %d and printed %q", code, stderr)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != fixture {
+		t.Errorf("-save-fixture wrote\n%s\nwant\n%s", data, fixture)
+	}
+
+	if standard, _, _ := runMain(t, "", "-api-base", api, "golang"); saved != standard {
+		t.Errorf("-save-fixture changed the output to\n%s\nwant\n%s", saved, standard)
+	}
+
+	// Failing to save is reported, but the query still succeeds
+	missing := filepath.Join(t.TempDir(), "missing", "golang.json")
+	stdout, stderr, code := runMain(t, "", "-api-base", api, "-save-fixture", missing, "golang")
+	if code != exitFound || stdout != saved || !strings.HasPrefix(stderr, "Failed to save -save-fixture: ") {
+		t.Errorf("-save-fixture to a missing directory exited with %d and printed %q", code, stderr)
+	}
+}
+
+func TestSaveFixtureNotJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "body.txt")
+	if err := saveFixture(path, []byte("<html>rate limited</html>")); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := os.ReadFile(path); string(data) != "<html>rate limited</html>" {
+		t.Errorf("saveFixture() wrote %q for a body that is not JSON", data)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// followRelated() chases the first related topic of a response for -follow, querying it and then
+// the first related topic of that answer, up to -follow-depth times. Each answer is preceded by
+// the trail of queries that led to it, and the chase stops early at a topic already visited
+func followRelated(response Response, options Options) error {
+	trail := []string{response.Query}
+	seen := map[string]bool{strings.ToLower(response.Query): true}
+
+	for depth := 0; depth < *flagFollowDepth; depth++ {
+		topics := flattenTopics(response.RelatedTopics)
+		if len(topics) == 0 {
+			return nil
+		}
+
+		next := topicQuery(topics[0])
+		if next == "" || seen[strings.ToLower(next)] {
+			logVerbose("not following %q, it was already visited", next)
+			return nil
+		}
+		seen[strings.ToLower(next)] = true
+		trail = append(trail, next)
+
+		fmt.Fprint(os.Stdout, blank())
+		fmt.Fprintln(os.Stdout, color("Yellow"), "Following: "+strings.Join(trail, " > ")+color("Reset"))
+
+		followed, err := answerQuery(next, options)
+		if err != nil {
+			return err
+		}
+
+		response = followed
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// chainAPI() stubs the API with a chain of answers, each query's first related topic naming the
+// next query in the chain and the last one naming the first again. It returns the -api-base and
+// a function listing the queries received so far
+func chainAPI(t *testing.T, chain ...string) (string, func() string) {
+	t.Helper()
+
+	next := map[string]string{}
+	for i, query := range chain {
+		next[query] = chain[(i+1)%len(chain)]
+	}
+
+	var mutex sync.Mutex
+	queries := []string{}
+	api := stubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("q")
+
+		mutex.Lock()
+		queries = append(queries, query)
+		mutex.Unlock()
+
+		serveJSON(fmt.Sprintf(`{
+			"AbstractText": "About %s.",
+			"Heading": %q,
+			"RelatedTopics": [{"FirstURL": "https://duckduckgo.com/%s", "Text": "%s topic"}],
+			"Type": "A"
+		}`, query, query, next[query], next[query]))(w, r)
+	})
+
+	return api, func() string {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return strings.Join(queries, ",")
+	}
+}
+
+func TestFollow(t *testing.T) {
+	api, queries := chainAPI(t, "alpha", "beta", "gamma", "delta")
+
+	stdout, stderr, code := runMain(t, "", "-api-base", api, "-abstract-only", "-follow", "-follow-depth", "2", "alpha")
+	if code != exitFound || stderr != "" {
+		t.Fatalf("-follow exited with %d and printed %q", code, stderr)
+	}
+
+	// Each answer is preceded by the trail that led to it, and the chase stops at the depth
+	want := "About alpha.\n\n Following: alpha > beta\nAbout beta.\n\n Following: alpha > beta > gamma\nAbout gamma.\n"
+	if stdout != want {
+		t.Errorf("-follow -follow-depth 2 printed\n%q\nwant\n%q", stdout, want)
+	}
+
+	if got := queries(); got != "alpha,beta,gamma" {
+		t.Errorf("-follow -follow-depth 2 queried %s", got)
+	}
+}
+
+func TestFollowCycle(t *testing.T) {
+	api, queries := chainAPI(t, "alpha", "beta")
+
+	stdout, stderr, code := runMain(t, "", "-api-base", api, "-abstract-only", "-follow", "-follow-depth", "10", "-v", "alpha")
+	if code != exitFound {
+		t.Fatalf("-follow exited with %d and printed %q", code, stderr)
+	}
+
+	// beta leads back to alpha, which is not queried again
+	if got := queries(); got != "alpha,beta" {
+		t.Errorf("-follow around a cycle queried %s", got)
+	}
+	if strings.Count(stdout, "Following:") != 1 || !strings.Contains(stderr, `not following "alpha", it was already visited`) {
+		t.Errorf("-follow around a cycle printed\n%s\n%s", stdout, stderr)
+	}
+}
+
+func TestFollowOff(t *testing.T) {
+	api, queries := chainAPI(t, "alpha", "beta")
+
+	stdout, _, code := runMain(t, "", "-api-base", api, "-abstract-only", "alpha")
+	if code != exitFound || stdout != "About alpha.\n" || queries() != "alpha" {
+		t.Errorf("without -follow the run exited with %d, printed %q and sent %q", code, stdout, queries())
+	}
+
+	_, stderr, code := runMain(t, "", "-api-base", api, "-follow", "-follow-depth", "deep", "alpha")
+	if code != exitUsage || !strings.Contains(stderr, `invalid value "deep" for flag -follow-depth`) {
+		t.Errorf("-follow-depth deep exited with %d and printed %q, want %d", code, stderr, exitUsage)
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Formatter renders a parsed Response. Fetching a response and formatting it are kept apart so
+// that new output formats can be added with RegisterFormatter() without touching the query path
+type Formatter interface {
+	Format(w io.Writer, r Response) error
+}
+
+// formatters are the output formats selectable by name with -format
+var formatters = map[string]Formatter{
+	"human":       HumanFormatter{},
+	"json":        JSONFormatter{},
+	"json-pretty": JSONFormatter{Pretty: true},
+	"markdown":    MarkdownFormatter{},
+	"html":        HTMLFormatter{},
+	"jsonl":       JSONLinesFormatter{},
+}
+
+// RegisterFormatter() makes a Formatter selectable with -format, replacing any formatter
+// already registered under the name
+func RegisterFormatter(name string, formatter Formatter) {
+	formatters[name] = formatter
+}
+
+// formatterNames() lists the registered formatters in alphabetical order
+func formatterNames() []string {
+	names := make([]string, 0, len(formatters))
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// selectFormatter() picks the formatter requested by the command-line flags. The dedicated
+// output flags take precedence over -format, which defaults to the human readable output
+func selectFormatter() (Formatter, error) {
+	switch {
+	case *flagTemplate != "":
+		return NewTemplateFormatter(*flagTemplate)
+	case *flagCountOnly:
+		return countFormatter{}, nil
+	case *flagAbstractOnly:
+		return abstractFormatter{}, nil
+	case *flagLucky:
+		return luckyFormatter{}, nil
+	case *flagSelect < 0:
+		return nil, fmt.Errorf("Invalid -select %d: topics are numbered from 1", *flagSelect)
+	case *flagSelect > 0:
+		return topicURLFormatter{Number: *flagSelect}, nil
+	case *flagRelatedOnly:
+		return relatedURLFormatter{}, nil
+	case *flagJSONLFull:
+		return JSONLinesFormatter{}, nil
+	case *flagJSON:
+		return JSONFormatter{}, nil
+	case *flagPretty:
+		return JSONFormatter{Pretty: true}, nil
+	case *flagHTML:
+		return HTMLFormatter{}, nil
+	}
+
+	formatter, ok := formatters[*flagFormat]
+	if !ok {
+		return nil, fmt.Errorf("Invalid -format %q: expected one of %s", *flagFormat, strings.Join(formatterNames(), ", "))
+	}
+
+	return formatter, nil
+}
+
+// HumanFormatter is the default colored, human readable output
+type HumanFormatter struct{}
+
+func (HumanFormatter) Format(w io.Writer, r Response) error {
+	if *flagDisambig && isDisambiguation(r) {
+		printDisambiguation(w, r)
+		return nil
+	}
+
+	return printResponse(w, r)
+}
+
+// JSONFormatter prints the response as JSON, on a single line or indented when Pretty is set.
+// The output stays valid JSON byte for byte unless colors are enabled
+type JSONFormatter struct {
+	Pretty bool
+}
+
+func (f JSONFormatter) Format(w io.Writer, r Response) error {
+	var data []byte
+	var err error
+
+	if f.Pretty {
+		data, err = json.MarshalIndent(r, "", "  ")
+	} else {
+		data, err = json.Marshal(r)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if colorEnabled() {
+		data = colorizeJSON(data)
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// JSONLinesFormatter prints the whole response along with its query as one JSON object per line,
+// for consumers of newline delimited JSON. Unlike JSONFormatter it is never colored
+type JSONLinesFormatter struct{}
+
+// jsonLine is the object printed by JSONLinesFormatter, the query is left out of Response's JSON
+type jsonLine struct {
+	Query string
+	Response
+}
+
+func (JSONLinesFormatter) Format(w io.Writer, r Response) error {
+	data, err := json.Marshal(jsonLine{Query: r.Query, Response: r})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// MarkdownFormatter prints the response as a Markdown document, with the related topics as a list of links
+type MarkdownFormatter struct{}
+
+func (MarkdownFormatter) Format(out io.Writer, r Response) error {
+	w := &stickyWriter{w: out}
+
+	fmt.Fprintf(w, "## %s", markdownEscape(heading(r)))
+	if r.Entity != "" {
+		fmt.Fprintf(w, " \\[%s\\]", markdownEscape(r.Entity))
+	}
+	fmt.Fprint(w, "\n\n")
+
+	for _, field := range answerPriority {
+		label, text := primaryText(r, field)
+		if text == "" {
+			continue
+		}
+
+		if label != "" {
+			fmt.Fprintf(w, "**%s:** ", label)
+		}
+		fmt.Fprintf(w, "%s\n\n", markdownEscape(text))
+	}
+
+	if r.AbstractURL != "" && !*flagNoAbstractURL {
+		fmt.Fprintf(w, "[More info](%s)\n\n", markdownURL(r.AbstractURL))
+	}
+
+	topics := displayedTopics(r)
+	if !showRelated(r) || len(topics) == 0 {
+		return w.err
+	}
+
+	fmt.Fprint(w, "### Related topics\n\n")
+
+	for _, topic := range topics {
+		if len(topic.Topics) > 0 {
+			fmt.Fprintf(w, "- **%s**\n", markdownEscape(topic.Name))
+			for _, nested := range flattenTopics(topic.Topics) {
+				fmt.Fprintf(w, "  - %s\n", markdownTopic(nested))
+			}
+			if topic.hidden > 0 {
+				fmt.Fprintf(w, "  - (%d more in %s)\n", topic.hidden, markdownEscape(topic.Name))
+			}
+			continue
+		}
+
+		fmt.Fprintf(w, "- %s\n", markdownTopic(topic))
+	}
+
+	fmt.Fprintln(w)
+
+	return w.err
+}
+
+// markdownTopic() formats a related topic as a Markdown link, after its -line-numbers label. The
+// period of the label is escaped, so that it does not start a numbered list inside the item
+func markdownTopic(topic RelatedTopic) string {
+	label, _ := numberLabel(topic, 1)
+
+	return strings.Replace(label, ".", `\.`, 1) + fmt.Sprintf("[%s](%s)", markdownEscape(topic.Text), markdownURL(topic.FirstURL))
+}
+
+// markdownEscape() escapes the characters that would otherwise be read as Markdown syntax
+func markdownEscape(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`,
+)
+
+// markdownURL() percent-encodes the characters that would end a Markdown link target early,
+// such as the parentheses in https://en.wikipedia.org/wiki/Go_(programming_language)
+func markdownURL(link string) string {
+	return markdownURLEscaper.Replace(link)
+}
+
+var markdownURLEscaper = strings.NewReplacer("(", "%28", ")", "%29", " ", "%20")
+
+// TemplateFormatter renders the response with a user supplied text/template, followed by a newline
+type TemplateFormatter struct {
+	Template *template.Template
+}
+
+// NewTemplateFormatter() parses the template text, reporting mistakes before any query is sent
+func NewTemplateFormatter(text string) (TemplateFormatter, error) {
+	tmpl, err := template.New("output").Parse(text)
+	if err != nil {
+		return TemplateFormatter{}, fmt.Errorf("Invalid -template: %v", err)
+	}
+
+	return TemplateFormatter{Template: tmpl}, nil
+}
+
+func (f TemplateFormatter) Format(w io.Writer, r Response) error {
+	if err := f.Template.Execute(w, r); err != nil {
+		return fmt.Errorf("Failed to render -template: %v", err)
+	}
+
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// countFormatter prints only the resultCount() of the response, for -count-only
+type countFormatter struct{}
+
+func (countFormatter) Format(w io.Writer, r Response) error {
+	_, err := fmt.Fprintln(w, resultCount(r))
+	return err
+}
+
+// abstractFormatter prints only the abstract text without colors or labels, for -abstract-only
+type abstractFormatter struct{}
+
+func (abstractFormatter) Format(w io.Writer, r Response) error {
+	if r.AbstractText == "" {
+		return nil
+	}
+
+	_, err := fmt.Fprintln(w, r.AbstractText)
+	return err
+}
+
+// relatedURLFormatter prints only the URL of each related topic, one per line, for -related-only
+type relatedURLFormatter struct{}
+
+func (relatedURLFormatter) Format(out io.Writer, r Response) error {
+	w := &stickyWriter{w: out}
+
+	for _, topic := range flattenTopics(limitTopics(r.RelatedTopics, *flagLimit)) {
+		if topic.FirstURL != "" {
+			fmt.Fprintln(w, topic.FirstURL)
+		}
+	}
+
+	return w.err
+}
+
+// topicURLFormatter prints only the URL of the Nth related topic, for -select
+type topicURLFormatter struct {
+	Number int
+}
+
+func (f topicURLFormatter) Format(w io.Writer, r Response) error {
+	link, err := selectedURL(r, f.Number)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, link)
+	return err
+}
+
+// luckyFormatter opens the bestURL() of the response in the browser instead of printing it, and
+// only confirms which page was opened, for -lucky
+type luckyFormatter struct{}
+
+func (luckyFormatter) Format(w io.Writer, r Response) error {
+	link := bestURL(r)
+	if link == "" {
+		return missingError{fmt.Sprintf("No URL to open for %q", r.Query)}
+	}
+
+	if err := openURL(link); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "Opened %s\n", link)
+	return err
+}
+
+// selectedURL() returns the URL of the Nth related topic, counting from 1 in the same order as
+// -related-only and :open, i.e. with the topics of categories flattened in
+func selectedURL(r Response, number int) (string, error) {
+	topics := flattenTopics(r.RelatedTopics)
+	if number < 1 || number > len(topics) {
+		return "", missingError{fmt.Sprintf("No related topic %d, the result has %s", number, plural(len(topics), "topic", "topics"))}
+	}
+
+	if topics[number-1].FirstURL == "" {
+		return "", missingError{fmt.Sprintf("Topic %d has no URL", number)}
+	}
+
+	return topics[number-1].FirstURL, nil
+}
+
+// missingError is returned by the formatters that print a single part of a result, such as
+// -select N, when the result does not have that part. The query itself did not fail, it found
+// nothing, so the error is reported without failing the run
+type missingError struct {
+	message string
+}
+
+func (e missingError) Error() string {
+	return e.message
+}
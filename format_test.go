@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTemplateFormatter(t *testing.T) {
+	apiBase := fixtureServer(t, "golang.json")
+
+	stdout, stderr, code := runMain(t, "", "-api-base", apiBase, "-s", "golang", "-template", "{{.Heading}} - {{.AbstractURL}}")
+	if code != exitFound {
+		t.Fatalf("exited with %d, want %d: %s", code, exitFound, stderr)
+	}
+
+	if want := "Go (programming language) - https://en.wikipedia.org/wiki/Go_(programming_language)\n"; stdout != want {
+		t.Errorf("printed %q, want %q", stdout, want)
+	}
+}
+
+func TestTemplateFormatterFails(t *testing.T) {
+	apiBase := fixtureServer(t, "golang.json")
+
+	// The template parses, but Response has no field Foo, which only shows once it is executed
+	_, stderr, code := runMain(t, "", "-api-base", apiBase, "-s", "golang", "-template", "{{.Foo}}")
+	if code != exitError {
+		t.Errorf("exited with %d, want %d", code, exitError)
+	}
+
+	if !strings.Contains(stderr, "Failed to render -template") {
+		t.Errorf("the failure was not reported, stderr is %q", stderr)
+	}
+
+	if _, _, code := runMain(t, "", "-s", "golang", "-template", "{{.AbstractText"); code != exitUsage {
+		t.Errorf("an invalid -template exited with %d, want %d", code, exitUsage)
+	}
+}
+
+func TestFormatters(t *testing.T) {
+	response, err := parseResponse("golang", readFixture(t, "golang.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	abstract := "Go is a statically typed, compiled programming language designed at Google."
+
+	tests := map[string][]string{
+		"human":       {"Go (programming language) [programming language]", abstract, "— via Wikipedia", "Related topics:"},
+		"json":        {`"AbstractText":"` + abstract + `"`},
+		"json-pretty": {`  "AbstractText": "` + abstract + `",`},
+		"jsonl":       {`{"Query":"golang",`, `"AbstractText":"` + abstract + `"`},
+		"markdown":    {"## Go (programming language) \\[programming language\\]", abstract, "### Related topics"},
+		"html":        {`<h2>Go (programming language) <small>[programming language]</small></h2>`, "<p>" + abstract + "</p>", "<ul>"},
+	}
+
+	for _, name := range formatterNames() {
+		want, ok := tests[name]
+		if !ok {
+			t.Errorf("the %s formatter is not tested", name)
+			continue
+		}
+
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := formatters[name].Format(&out, response); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, text := range want {
+				if !strings.Contains(out.String(), text) {
+					t.Errorf("the output has no %q:\n%s", text, out.String())
+				}
+			}
+
+			if strings.HasPrefix(name, "json") && !json.Valid(out.Bytes()) {
+				t.Errorf("the output is not valid JSON:\n%s", out.String())
+			}
+		})
+	}
+}
+
+// upperFormatter prints the query in capitals, a format registered by TestRegisterFormatter
+type upperFormatter struct{}
+
+func (upperFormatter) Format(w io.Writer, r Response) error {
+	_, err := fmt.Fprintln(w, strings.ToUpper(r.Query))
+	return err
+}
+
+func TestRegisterFormatter(t *testing.T) {
+	RegisterFormatter("upper", upperFormatter{})
+	t.Cleanup(func() { delete(formatters, "upper") })
+
+	setFlag(t, "format", "upper")
+
+	formatter, err := selectFormatter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := formatter.(upperFormatter); !ok {
+		t.Errorf("-format upper selected %T", formatter)
+	}
+
+	setFlag(t, "format", "yaml")
+	if _, err := selectFormatter(); err == nil || !strings.Contains(err.Error(), "upper") {
+		t.Errorf("got the error %v, want the registered formats listed", err)
+	}
+}
+
+func TestHTMLFormatterEscapes(t *testing.T) {
+	script := "<script>alert(1)</script>"
+
+	response := Response{
+		Query:        "golang",
+		Heading:      script,
+		Entity:       script,
+		AbstractText: script,
+		AbstractURL:  "javascript:alert(1)",
+		RelatedTopics: []RelatedTopic{
+			{FirstURL: "https://duckduckgo.com/Rob_Pike", Text: script},
+			{Name: script, Topics: []RelatedTopic{{FirstURL: "javascript:alert(1)", Text: script}}},
+		},
+	}
+
+	var out bytes.Buffer
+	if err := (HTMLFormatter{}).Format(&out, response); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(out.String(), "<script>") || strings.Contains(out.String(), "javascript:") {
+		t.Errorf("the API's text was not escaped:\n%s", out.String())
+	}
+
+	if got := strings.Count(out.String(), "&lt;script&gt;alert(1)&lt;/script&gt;"); got < 4 {
+		t.Errorf("found the escaped text %d times, want it in the heading, abstract and topics:\n%s", got, out.String())
+	}
+}
+
+func TestDocumentFormattersTopics(t *testing.T) {
+	setFlag(t, "max-per-category", "1")
+	setFlag(t, "line-numbers", "true")
+
+	response, err := parseResponse("golang", readFixture(t, "golang.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The numbers count every topic, including the one -max-per-category leaves out
+	tests := map[string][]string{
+		"markdown": {
+			"- 1\\. [Rob Pike Canadian programmer](https://duckduckgo.com/Rob_Pike)\n",
+			"  - 3\\. [C (programming language) general-purpose](https://duckduckgo.com/C_%28programming_language%29)\n",
+			"  - (1 more in Languages)\n",
+		},
+		"html": {
+			`<li>1. <a href="https://duckduckgo.com/Rob_Pike">Rob Pike Canadian programmer</a></li>`,
+			`<li>3. <a href="https://duckduckgo.com/C_%28programming_language%29">C (programming language) general-purpose</a></li>`,
+			"<li>(1 more in Languages)</li>",
+		},
+	}
+
+	for name, want := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := formatters[name].Format(&out, response); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, text := range want {
+				if !strings.Contains(out.String(), text) {
+					t.Errorf("the output has no %q:\n%s", text, out.String())
+				}
+			}
+
+			if strings.Contains(out.String(), "Limbo") {
+				t.Errorf("-max-per-category 1 kept the second topic of the category:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestRelatedOnly(t *testing.T) {
+	api := fixtureServer(t, "golang.json")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "https://duckduckgo.com/Rob_Pike\nhttps://duckduckgo.com/Ken_Thompson\nhttps://duckduckgo.com/C_(programming_language)\nhttps://en.wikipedia.org/wiki/Limbo\n"},
+		// The topics of a category count towards -limit one by one
+		{[]string{"-limit", "3"}, "https://duckduckgo.com/Rob_Pike\nhttps://duckduckgo.com/Ken_Thompson\nhttps://duckduckgo.com/C_(programming_language)\n"},
+		{[]string{"-limit", "1"}, "https://duckduckgo.com/Rob_Pike\n"},
+	}
+
+	for _, test := range tests {
+		args := append(append([]string{"-api-base", api, "-related-only"}, test.args...), "golang")
+
+		stdout, stderr, code := runMain(t, "", args...)
+		if code != exitFound || stderr != "" {
+			t.Errorf("%q exited with %d and printed %q", test.args, code, stderr)
+		}
+
+		if stdout != test.want {
+			t.Errorf("-related-only %q printed\n%s\nwant\n%s", test.args, stdout, test.want)
+		}
+	}
+
+	_, stderr, code := runMain(t, "", "-api-base", api, "-related-only", "-limit", "some", "golang")
+	if code != exitUsage || !strings.Contains(stderr, `invalid value "some" for flag -limit`) {
+		t.Errorf("-limit some exited with %d and printed %q, want %d", code, stderr, exitUsage)
+	}
+}
+
+func TestAbstractOnly(t *testing.T) {
+	stdout, stderr, code := runMain(t, "", "-api-base", fixtureServer(t, "golang.json"), "-abstract-only", "-s", "golang")
+	if code != exitFound || stderr != "" {
+		t.Errorf("-abstract-only exited with %d and printed %q", code, stderr)
+	}
+
+	if want := "Go is a statically typed, compiled programming language designed at Google.\n"; stdout != want {
+		t.Errorf("-abstract-only printed %q, want %q", stdout, want)
+	}
+
+	// The definition is an answer, but not the abstract that was asked for
+	stdout, stderr, code = runMain(t, "", "-api-base", fixtureServer(t, "define.json"), "-abstract-only", "-s", "monad")
+	if code != exitNoResults || stdout != "" || stderr != "" {
+		t.Errorf("-abstract-only without an abstract exited with %d and printed %q, %q, want %d", code, stdout, stderr, exitNoResults)
+	}
+
+	_, stderr, code = runMain(t, "", "-abstract-only=maybe", "-s", "golang")
+	if code != exitUsage || !strings.Contains(stderr, "invalid boolean value") {
+		t.Errorf("-abstract-only=maybe exited with %d and printed %q, want %d", code, stderr, exitUsage)
+	}
+}
+
+func TestJSONCompactPretty(t *testing.T) {
+	api := fixtureServer(t, "golang.json")
+
+	run := func(args ...string) string {
+		t.Helper()
+
+		stdout, stderr, code := runMain(t, "", append(append([]string{"-api-base", api}, args...), "golang")...)
+		if code != exitFound || stderr != "" {
+			t.Fatalf("%q exited with %d and printed %q", args, code, stderr)
+		}
+
+		return stdout
+	}
+
+	compact, pretty := run("-json"), run("-json-pretty")
+
+	if strings.Count(compact, "\n") != 1 || !strings.HasSuffix(compact, "}\n") || strings.Contains(compact, "  ") {
+		t.Errorf("-json is not a single compact line:\n%s", compact)
+	}
+
+	if strings.Count(pretty, "\n") < 10 || !strings.Contains(pretty, "\n  \"AbstractText\": ") {
+		t.Errorf("-json-pretty is not indented:\n%s", pretty)
+	}
+
+	// Both are the same Response
+	var fromCompact, fromPretty Response
+	if err := json.Unmarshal([]byte(compact), &fromCompact); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(pretty), &fromPretty); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromCompact, fromPretty) || fromCompact.Heading != "Go (programming language)" {
+		t.Errorf("-json decodes to %+v, -json-pretty to %+v", fromCompact, fromPretty)
+	}
+
+	if format := run("-format", "json-pretty"); format != pretty {
+		t.Errorf("-format json-pretty printed\n%s\nwant the -json-pretty output", format)
+	}
+
+	_, stderr, code := runMain(t, "", "-api-base", api, "-format", "yaml", "golang")
+	if code != exitUsage || !strings.Contains(stderr, `Invalid -format "yaml"`) {
+		t.Errorf("-format yaml exited with %d and printed %q, want %d", code, stderr, exitUsage)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	api := fixtureServer(t, "golang.json")
+
+	// The topics of a category are counted in order after the topics before them
+	for number, want := range map[string]string{
+		"1": "https://duckduckgo.com/Rob_Pike\n",
+		"3": "https://duckduckgo.com/C_(programming_language)\n",
+		"4": "https://en.wikipedia.org/wiki/Limbo\n",
+	} {
+		stdout, stderr, code := runMain(t, "", "-api-base", api, "-select", number, "-s", "golang")
+		if code != exitFound || stderr != "" || stdout != want {
+			t.Errorf("-select %s exited with %d and printed %q, %q, want %q", number, code, stdout, stderr, want)
+		}
+	}
+
+	stdout, stderr, code := runMain(t, "", "-api-base", api, "-select", "5", "-s", "golang")
+	if code != exitNoResults || stdout != "" {
+		t.Errorf("-select 5 exited with %d and printed %q, want %d", code, stdout, exitNoResults)
+	}
+	if want := "No related topic 5, the result has 4 topics"; !strings.Contains(stderr, want) {
+		t.Errorf("-select 5 printed %q, want %q", stderr, want)
+	}
+
+	_, stderr, code = runMain(t, "", "-api-base", api, "-select", "-1", "-s", "golang")
+	if code != exitUsage || !strings.Contains(stderr, "Invalid -select -1: topics are numbered from 1") {
+		t.Errorf("-select -1 exited with %d and printed %q, want %d", code, stderr, exitUsage)
+	}
+}
+
+func TestJSONLinesFull(t *testing.T) {
+	api := fixtureServer(t, "golang.json")
+	fixture, err := parseResponse("golang", readFixture(t, "golang.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Colors are forced to check that they never make it into the lines
+	stdout, stderr, code := runMain(t, "golang\ngo programming\n", "-api-base", api, "-jsonl-full", "-color", "always")
+	if code != exitFound || stderr != "" {
+		t.Fatalf("-jsonl-full exited with %d and printed %q", code, stderr)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("-jsonl-full printed %d lines, want one per query:\n%s", len(lines), stdout)
+	}
+
+	for i, query := range []string{"golang", "go programming"} {
+		var line jsonLine
+		if err := json.Unmarshal([]byte(lines[i]), &line); err != nil {
+			t.Fatalf("line %d is not JSON: %v\n%s", i+1, err, lines[i])
+		}
+
+		if line.Query != query {
+			t.Errorf("line %d is for %q, want %q", i+1, line.Query, query)
+		}
+
+		if len(line.RelatedTopics) == 0 || !reflect.DeepEqual(line.RelatedTopics, fixture.RelatedTopics) {
+			t.Errorf("line %d does not hold the related topics: %+v", i+1, line.RelatedTopics)
+		}
+	}
+
+	if format, _, _ := runMain(t, "golang\ngo programming\n", "-api-base", api, "-format", "jsonl"); format != stdout {
+		t.Errorf("-format jsonl printed\n%s\nwant the -jsonl-full output", format)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// stringList is a flag.Value collecting every use of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// flagHeaders collects the repeatable -header flag
+var flagHeaders stringList
+
+// requestHeaders are added to every API request, they are built at startup from -header and -basic-auth
+var requestHeaders = http.Header{}
+
+// parseHeader() splits a "Key: Value" header flag into its name and value
+func parseHeader(header string) (string, string, error) {
+	colon := strings.Index(header, ":")
+	if colon < 0 {
+		return "", "", fmt.Errorf("Invalid -header %q: expected \"Key: Value\"", header)
+	}
+
+	name := strings.TrimSpace(header[:colon])
+	value := strings.TrimSpace(header[colon+1:])
+
+	if name == "" || strings.ContainsAny(name, " \t\r\n()<>@,;\\\"/[]?={}") {
+		return "", "", fmt.Errorf("Invalid -header %q: %q is not a valid header name", header, name)
+	}
+
+	if strings.ContainsAny(value, "\r\n") {
+		return "", "", fmt.Errorf("Invalid -header %q: values cannot contain line breaks", header)
+	}
+
+	return name, value, nil
+}
+
+// buildRequestHeaders() validates the -header and -basic-auth flags and returns the headers they describe
+func buildRequestHeaders(headers []string, basicAuth string) (http.Header, error) {
+	built := http.Header{}
+
+	for _, header := range headers {
+		name, value, err := parseHeader(header)
+		if err != nil {
+			return nil, err
+		}
+
+		built.Add(name, value)
+	}
+
+	if basicAuth != "" {
+		if !strings.Contains(basicAuth, ":") {
+			return nil, fmt.Errorf("Invalid -basic-auth: expected \"user:pass\"")
+		}
+
+		built.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(basicAuth)))
+	}
+
+	return built, nil
+}
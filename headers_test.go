@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestRequestHeaders(t *testing.T) {
+	headers, err := buildRequestHeaders([]string{"X-Api-Key: abc123", "X-Trace: one", "X-Trace: two"}, "user:secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := requestHeaders
+	requestHeaders = headers
+	t.Cleanup(func() { requestHeaders = previous })
+
+	received := make(chan http.Header, 1)
+	apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+		w.Header().Set("Content-Type", "application/x-javascript")
+		io.WriteString(w, `{"AbstractText":"","RelatedTopics":[]}`)
+	})
+
+	if _, err := queryResponse("golang", defaultOptions(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	got := <-received
+	if got.Get("X-Api-Key") != "abc123" {
+		t.Errorf("X-Api-Key is %q, want %q", got.Get("X-Api-Key"), "abc123")
+	}
+
+	if values := got.Values("X-Trace"); len(values) != 2 || values[0] != "one" || values[1] != "two" {
+		t.Errorf("X-Trace is %q, want both values in order", values)
+	}
+
+	if user, pass, ok := (&http.Request{Header: got}).BasicAuth(); !ok || user != "user" || pass != "secret" {
+		t.Errorf("Basic credentials are %q:%q, want user:secret", user, pass)
+	}
+}
+
+func TestBuildRequestHeadersInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		headers   []string
+		basicAuth string
+	}{
+		{"no colon", []string{"X-Api-Key abc123"}, ""},
+		{"empty name", []string{": abc123"}, ""},
+		{"space in name", []string{"X Api Key: abc123"}, ""},
+		{"line break in value", []string{"X-Api-Key: abc\r\nX-Other: 1"}, ""},
+		{"basic auth without password", nil, "user"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := buildRequestHeaders(test.headers, test.basicAuth); err == nil {
+				t.Errorf("buildRequestHeaders(%q, %q) accepted invalid input", test.headers, test.basicAuth)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"html/template"
+	"io"
+)
+
+// htmlFragment renders a response as an HTML fragment, html/template escapes the API's text and
+// only lets safe URLs into the links
+var htmlFragment = template.Must(template.New("html").Parse(`<div class="ddg-answer">
+<h2>{{.Heading}}{{with .Response.Entity}} <small>[{{.}}]</small>{{end}}</h2>
+{{- range .Primary}}
+<p>{{if .Label}}<strong>{{.Label}}:</strong> {{end}}{{.Text}}</p>
+{{- end}}
+{{- if .MoreInfo}}
+<p><a href="{{.MoreInfo}}">More info</a></p>
+{{- end}}
+{{- if .Topics}}
+<ul>
+{{- range .Topics}}
+{{- if .Topics}}
+<li>{{.Name}}
+<ul>
+{{- range .Topics}}
+<li>{{.Label}}<a href="{{.FirstURL}}">{{.Text}}</a></li>
+{{- end}}
+{{- if .Hidden}}
+<li>({{.Hidden}} more in {{.Name}})</li>
+{{- end}}
+</ul>
+</li>
+{{- else}}
+<li>{{.Label}}<a href="{{.FirstURL}}">{{.Text}}</a></li>
+{{- end}}
+{{- end}}
+</ul>
+{{- end}}
+</div>
+`))
+
+// htmlField is one of the answerFields as shown in the fragment
+type htmlField struct {
+	Label, Text string
+}
+
+// htmlTopic is a related topic as shown in the fragment, the template cannot read the unexported
+// -line-numbers and -max-per-category details of a RelatedTopic
+type htmlTopic struct {
+	Label, FirstURL, Text, Name string
+	Topics                      []htmlTopic
+
+	// Hidden counts the topics of a category left out by -max-per-category
+	Hidden int
+}
+
+// htmlTopics() converts the topics, and those nested in categories, for the fragment
+func htmlTopics(topics []RelatedTopic) []htmlTopic {
+	converted := make([]htmlTopic, 0, len(topics))
+
+	for _, topic := range topics {
+		label, _ := numberLabel(topic, 1)
+		converted = append(converted, htmlTopic{
+			Label:    label,
+			FirstURL: topic.FirstURL,
+			Text:     topic.Text,
+			Name:     topic.Name,
+			Topics:   htmlTopics(flattenTopics(topic.Topics)),
+			Hidden:   topic.hidden,
+		})
+	}
+
+	return converted
+}
+
+// HTMLFormatter prints the response as an HTML fragment for embedding in a web page
+type HTMLFormatter struct{}
+
+func (HTMLFormatter) Format(w io.Writer, r Response) error {
+	data := struct {
+		Response Response
+		Heading  string
+		Primary  []htmlField
+		MoreInfo string
+		Topics   []htmlTopic
+	}{Response: r, Heading: heading(r)}
+
+	for _, field := range answerPriority {
+		if label, text := primaryText(r, field); text != "" {
+			data.Primary = append(data.Primary, htmlField{Label: label, Text: text})
+		}
+	}
+
+	if !*flagNoAbstractURL {
+		data.MoreInfo = r.AbstractURL
+	}
+
+	if showRelated(r) {
+		data.Topics = htmlTopics(displayedTopics(r))
+	}
+
+	return htmlFragment.Execute(w, data)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// imageBase is prepended to the image paths of responses, which are relative to duckduckgo.com
+const imageBase = "https://duckduckgo.com"
+
+// imageURL() returns the absolute URL of a response's image, e.g. for "/i/e5ade1a1.png"
+func imageURL(image string) string {
+	if strings.HasPrefix(image, "/") {
+		return imageBase + image
+	}
+
+	return image
+}
+
+// imageDetails() describes the image of a response for -image-info, e.g. "300x300, logo".
+// Dimensions the API does not know are left out
+func imageDetails(input Response) string {
+	details := []string{}
+
+	if input.ImageWidth > 0 && input.ImageHeight > 0 {
+		details = append(details, fmt.Sprintf("%dx%d", input.ImageWidth, input.ImageHeight))
+	}
+
+	if input.ImageIsLogo != 0 {
+		details = append(details, "logo")
+	}
+
+	return strings.Join(details, ", ")
+}
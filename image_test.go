@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImageInfo(t *testing.T) {
+	response, err := parseResponse("gopher", readFixture(t, "image.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The width is sent as a string, as the API does for some responses
+	if response.ImageWidth != 512 || response.ImageHeight != 256 || response.ImageIsLogo != 1 {
+		t.Errorf("the image is %dx%d, logo %d, want 512x256, logo 1", response.ImageWidth, response.ImageHeight, response.ImageIsLogo)
+	}
+
+	if out := renderFixture(t, "image.json"); strings.Contains(out, "Image:") || strings.Contains(out, "512x256") {
+		t.Errorf("the image was printed without -image-info:\n%s", out)
+	}
+
+	setFlag(t, "image-info", "true")
+
+	out := renderFixture(t, "image.json")
+	if want := "Image:\n \thttps://duckduckgo.com/i/go-gopher.png\n \t512x256, logo\n"; !strings.Contains(out, want) {
+		t.Errorf("-image-info printed\n%s\nwithout\n%s", out, want)
+	}
+
+	// golang.json sends a logo of known size too, define.json has no image at all
+	if out := renderFixture(t, "golang.json"); !strings.Contains(out, "\t300x300, logo\n") {
+		t.Errorf("-image-info printed\n%s", out)
+	}
+	if out := renderFixture(t, "define.json"); strings.Contains(out, "Image:") {
+		t.Errorf("-image-info printed an image for a response without one:\n%s", out)
+	}
+}
+
+func TestImageDetails(t *testing.T) {
+	tests := []struct {
+		response Response
+		want     string
+	}{
+		{Response{ImageWidth: 640, ImageHeight: 480}, "640x480"},
+		{Response{ImageWidth: 640, ImageIsLogo: 1}, "logo"},
+		{Response{}, ""},
+	}
+
+	for _, test := range tests {
+		if got := imageDetails(test.response); got != test.want {
+			t.Errorf("imageDetails(%+v) = %q, want %q", test.response, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sync"
+)
+
+// inputLine is a line read from stdin, along with the error that ended the read, if any
+type inputLine struct {
+	text string
+	err  error
+}
+
+// lineInput reads stdin one line at a time in the background, so that interactive mode can watch
+// for input, such as a request to cancel, while a query is running. Lines that arrive while
+// nobody is waiting for them are kept for the next prompt
+type lineInput struct {
+	reader *bufio.Reader
+
+	once    sync.Once
+	lines   chan inputLine
+	pending []inputLine
+}
+
+// stdinReader is shared by every prompt so that buffered input is never lost between reads
+var stdinReader = &lineInput{reader: bufio.NewReader(os.Stdin)}
+
+// start() launches the background reader, the first time input is needed
+func (in *lineInput) start() {
+	in.once.Do(func() {
+		in.lines = make(chan inputLine)
+
+		go func() {
+			defer close(in.lines)
+
+			for {
+				text, err := in.reader.ReadString('\n')
+				in.lines <- inputLine{text: text, err: err}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	})
+}
+
+// readLine() returns the next line of input, including its line break, like bufio.Reader.ReadString()
+func (in *lineInput) readLine() (string, error) {
+	if len(in.pending) > 0 {
+		line := in.pending[0]
+		in.pending = in.pending[1:]
+		return line.text, line.err
+	}
+
+	line, ok := <-in.next()
+	if !ok {
+		return "", io.EOF
+	}
+
+	return line.text, line.err
+}
+
+// next() is the channel the lines of input arrive on, it is closed after the end of input
+func (in *lineInput) next() <-chan inputLine {
+	in.start()
+	return in.lines
+}
+
+// unread() keeps a line received from next() for the following readLine()
+func (in *lineInput) unread(line inputLine) {
+	in.pending = append(in.pending, line)
+}
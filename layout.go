@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// defaultTerminalWidth is used whenever the width of the terminal is unknown
+	defaultTerminalWidth = 80
+
+	// minTerminalWidth keeps the layouts readable on a terminal reporting an implausibly small width
+	minTerminalWidth = 20
+)
+
+// terminalWidth() returns the number of columns that the box, -columns and the other layouts fit
+// in: -width when given, else the width of the terminal on stdout, else COLUMNS as exported by the
+// shell, else defaultTerminalWidth. It never fails, so callers need no fallback of their own
+func terminalWidth() int {
+	if *flagWidth > 0 {
+		return *flagWidth
+	}
+
+	width, ok := detectWidth()
+	if !ok {
+		columns, err := strconv.Atoi(os.Getenv("COLUMNS"))
+		if err != nil || columns <= 0 {
+			logVerbose("the terminal width is unknown, using %d columns", defaultTerminalWidth)
+			return defaultTerminalWidth
+		}
+		width = columns
+	}
+
+	if width < minTerminalWidth {
+		return minTerminalWidth
+	}
+
+	return width
+}
+
+// wrapText() breaks text into lines of at most width characters, splitting on whitespace.
+// Words longer than width are placed on a line of their own
+func wrapText(text string, width int) []string {
+	lines := []string{}
+	line := ""
+
+	for _, word := range strings.Fields(text) {
+		switch {
+		case line == "":
+			line = word
+		case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// boxStyle holds the characters used to draw the border of a box
+type boxStyle struct {
+	topLeft, topRight, bottomLeft, bottomRight string
+	horizontal, vertical                       string
+}
+
+var (
+	unicodeBox = boxStyle{"┌", "┐", "└", "┘", "─", "│"}
+	asciiBox   = boxStyle{"+", "+", "+", "+", "-", "|"}
+)
+
+// drawBox() prints the lines inside of a border, the box is as wide as its longest line
+func drawBox(w io.Writer, lines []string, style boxStyle) {
+	width := 0
+	for _, line := range lines {
+		if length := utf8.RuneCountInString(line); length > width {
+			width = length
+		}
+	}
+
+	horizontal := strings.Repeat(style.horizontal, width+2)
+
+	fmt.Fprintln(w, style.topLeft+horizontal+style.topRight)
+	for _, line := range lines {
+		padding := strings.Repeat(" ", width-utf8.RuneCountInString(line))
+		fmt.Fprintln(w, style.vertical+" "+line+padding+" "+style.vertical)
+	}
+	fmt.Fprintln(w, style.bottomLeft+horizontal+style.bottomRight)
+}
+
+// printAnswerBox() draws the answer and abstract of a response as a card, wrapped to fit the terminal
+func printAnswerBox(w io.Writer, input Response) {
+	style := unicodeBox
+	if *flagASCII {
+		style = asciiBox
+	}
+
+	// Leave room for the border and the padding on either side of the text
+	width := terminalWidth() - 4
+
+	lines := []string{}
+	for _, field := range answerPriority {
+		label, text := primaryText(input, field)
+		if text == "" {
+			continue
+		}
+
+		if label != "" {
+			text = label + ": " + text
+		}
+
+		// Separate the fields with an empty line
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+
+		lines = append(lines, wrapText(text, width)...)
+	}
+
+	if len(lines) == 0 {
+		return
+	}
+
+	// Credit the abstract to its source as printAbstract() does, on the last line of the box
+	if input.AbstractText != "" && input.AbstractSource != "" {
+		lines = append(lines, wrapText("— via "+input.AbstractSource, width)...)
+	}
+
+	fmt.Fprintln(w)
+	drawBox(w, lines, style)
+	fmt.Fprintln(w)
+}
+
+// columnGap separates the columns of a -columns layout
+const columnGap = "  "
+
+// columnCount() returns how many columns the cells are laid out in, for -columns. A requested
+// count of 0 fits as many columns as the width allows, and any count is lowered until the
+// widest cells fit, down to a single column when they cannot
+func columnCount(cells []string, requested int, width int) int {
+	columns := requested
+	if columns <= 0 || columns > len(cells) {
+		columns = len(cells)
+	}
+
+	for ; columns > 1; columns-- {
+		if layoutWidth(layoutColumns(cells, columns)) <= width {
+			return columns
+		}
+	}
+
+	return 1
+}
+
+// layoutColumns() splits the cells into balanced columns that are read top to bottom, then left
+// to right. The last column is the only one that may be shorter
+func layoutColumns(cells []string, columns int) [][]string {
+	rows := (len(cells) + columns - 1) / columns
+
+	layout := [][]string{}
+	for start := 0; start < len(cells); start += rows {
+		end := start + rows
+		if end > len(cells) {
+			end = len(cells)
+		}
+
+		layout = append(layout, cells[start:end])
+	}
+
+	return layout
+}
+
+// layoutWidth() returns the number of characters taken by the widest row of a column layout
+func layoutWidth(layout [][]string) int {
+	width := 0
+	for i, column := range layout {
+		if i > 0 {
+			width += len(columnGap)
+		}
+		width += columnWidth(column)
+	}
+
+	return width
+}
+
+func columnWidth(column []string) int {
+	width := 0
+	for _, cell := range column {
+		if length := utf8.RuneCountInString(cell); length > width {
+			width = length
+		}
+	}
+
+	return width
+}
+
+// columnRows() joins a column layout into lines, padding each cell to the width of its column
+func columnRows(layout [][]string) []string {
+	if len(layout) == 0 {
+		return nil
+	}
+
+	widths := make([]int, len(layout))
+	for i, column := range layout {
+		widths[i] = columnWidth(column)
+	}
+
+	lines := []string{}
+	for row := range layout[0] {
+		line := ""
+		for i, column := range layout {
+			if row >= len(column) {
+				break
+			}
+
+			if i > 0 {
+				line += columnGap
+			}
+
+			line += column[row]
+			if i < len(layout)-1 {
+				line += strings.Repeat(" ", widths[i]-utf8.RuneCountInString(column[row]))
+			}
+		}
+
+		lines = append(lines, strings.TrimRight(line, " "))
+	}
+
+	return lines
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestAnswerBox(t *testing.T) {
+	setFlag(t, "width", "40")
+
+	response, err := parseResponse("golang", readFixture(t, "golang.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ascii := range []bool{false, true} {
+		setFlag(t, "ascii", strconv.FormatBool(ascii))
+
+		style := unicodeBox
+		if ascii {
+			style = asciiBox
+		}
+
+		var out bytes.Buffer
+		printAnswerBox(&out, response)
+
+		lines := strings.Split(strings.Trim(out.String(), "\n"), "\n")
+		if len(lines) < 3 {
+			t.Fatalf("the box is only %q", lines)
+		}
+
+		top, bottom, inside := lines[0], lines[len(lines)-1], lines[1:len(lines)-1]
+		if !strings.HasPrefix(top, style.topLeft) || !strings.HasSuffix(top, style.topRight) {
+			t.Errorf("the top border is %q", top)
+		}
+		if !strings.HasPrefix(bottom, style.bottomLeft) || !strings.HasSuffix(bottom, style.bottomRight) {
+			t.Errorf("the bottom border is %q", bottom)
+		}
+
+		// Every line is as wide as the borders, and the box fits the 40 columns
+		width := utf8.RuneCountInString(top)
+		if width > 40 {
+			t.Errorf("the box is %d columns wide, -width is 40", width)
+		}
+
+		text := []string{}
+		for _, line := range inside {
+			if utf8.RuneCountInString(line) != width || !strings.HasPrefix(line, style.vertical+" ") || !strings.HasSuffix(line, " "+style.vertical) {
+				t.Errorf("the line %q is not framed to the width %d", line, width)
+			}
+			text = append(text, strings.TrimSpace(strings.Trim(line, style.vertical)))
+		}
+
+		// The abstract is wrapped inside the box and credited on the last line
+		want := "Go is a statically typed, compiled programming language designed at Google. — via Wikipedia"
+		if got := strings.Join(text, " "); got != want {
+			t.Errorf("the box holds %q, want %q", got, want)
+		}
+	}
+}
+
+func TestAnswerBoxOnlyOnTerminal(t *testing.T) {
+	setFlag(t, "box", "true")
+
+	// The output of the tests is not a terminal
+	out := renderFixture(t, "golang.json")
+	if strings.ContainsAny(out, unicodeBox.topLeft+unicodeBox.vertical) {
+		t.Errorf("the box was drawn on piped output:\n%s", out)
+	}
+}
+
+func TestAbstractSourceCredit(t *testing.T) {
+	setFlag(t, "width", "60")
+
+	response, err := parseResponse("rosetta stone", readFixture(t, "source.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credit := "— via Encyclopaedia Britannica"
+	if out := renderFixture(t, "source.json"); !strings.Contains(out, " a decree. "+credit+" \n") {
+		t.Errorf("the abstract is not credited to its source:\n%s", out)
+	}
+
+	var box bytes.Buffer
+	printAnswerBox(&box, response)
+	lines := strings.Split(strings.Trim(box.String(), "\n"), "\n")
+	if last := lines[len(lines)-2]; !strings.Contains(last, credit) {
+		t.Errorf("the last line of the box is %q, want the credit:\n%s", last, box.String())
+	}
+
+	// Without a source there is nothing to credit
+	response.AbstractSource = ""
+	var out bytes.Buffer
+	if err := printResponse(&out, response); err != nil {
+		t.Fatal(err)
+	}
+	box.Reset()
+	printAnswerBox(&box, response)
+	if strings.Contains(out.String(), "via") || strings.Contains(box.String(), "via") {
+		t.Errorf("an abstract without a source was credited:\n%s\n%s", out.String(), box.String())
+	}
+}
+
+func TestColumnLayout(t *testing.T) {
+	cells := []string{"Ken Thompson", "Dennis Ritchie", "Brian Kernighan", "Doug McIlroy", "Linux"}
+
+	tests := []struct {
+		requested int
+		width     int
+		columns   int
+	}{
+		{2, 80, 2},
+		{3, 80, 3},
+		// Too many columns for the width are lowered until the cells fit
+		{3, 30, 2},
+		{2, 20, 1},
+		// 0 fits as many as the width allows, never more than there are cells
+		{0, 30, 2},
+		{0, 200, 5},
+		{9, 200, 5},
+	}
+
+	for _, test := range tests {
+		if got := columnCount(cells, test.requested, test.width); got != test.columns {
+			t.Errorf("columnCount(%d, width %d) = %d, want %d", test.requested, test.width, got, test.columns)
+		}
+	}
+
+	// The columns are balanced, read top to bottom, and aligned at the widest cell plus the gap
+	want := []string{
+		"Ken Thompson     Doug McIlroy",
+		"Dennis Ritchie   Linux",
+		"Brian Kernighan",
+	}
+	if got := columnRows(layoutColumns(cells, 2)); !reflect.DeepEqual(got, want) {
+		t.Errorf("two columns are\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestTopicColumns(t *testing.T) {
+	setFlag(t, "width", "60")
+	setFlag(t, "columns", "2")
+
+	response, err := parseResponse("unix", readFixture(t, "categories.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	printTopicColumns(&out, response.RelatedTopics)
+
+	want := strings.Join([]string{
+		" \tBell Labs American research company",
+		" \tPeople:",
+		" \tKen Thompson    Brian Kernighan",
+		" \tDennis Ritchie  Doug McIlroy",
+		" \tDescendants:",
+		" \tLinux",
+		" \tShells:",
+		" \tBourne shell  KornShell",
+		" \tC shell",
+	}, "\n") + "\n\n"
+
+	if out.String() != want {
+		t.Errorf("-columns 2 printed\n%q\nwant\n%q", out.String(), want)
+	}
+
+	// Piped output keeps to a single column
+	if rendered := renderFixture(t, "categories.json"); !strings.Contains(rendered, " \tKen Thompson\n") {
+		t.Errorf("-columns 2 changed the piped output:\n%s", rendered)
+	}
+
+	_, stderr, code := runMain(t, "", "-columns", "-1", "-s", "unix")
+	if code != exitUsage || !strings.Contains(stderr, "Invalid -columns -1: expected 0 for automatic or a number of columns") {
+		t.Errorf("-columns -1 exited with %d and printed %q, want %d", code, stderr, exitUsage)
+	}
+}
+
+func TestTerminalWidth(t *testing.T) {
+	// The size of a pipe cannot be detected, as on a system without the ioctl
+	_, pipe, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pipe.Close()
+
+	previous := os.Stdout
+	os.Stdout = pipe
+	defer func() { os.Stdout = previous }()
+
+	if _, ok := detectWidth(); ok {
+		t.Fatal("the width of a pipe was detected")
+	}
+
+	tests := []struct {
+		width, columns string
+		want           int
+	}{
+		{"0", "", defaultTerminalWidth},
+		{"0", "wide", defaultTerminalWidth},
+		{"0", "-5", defaultTerminalWidth},
+		{"0", "132", 132},
+		{"0", "8", minTerminalWidth},
+		{"50", "132", 50},
+	}
+
+	for _, test := range tests {
+		setFlag(t, "width", test.width)
+		setEnv(t, "COLUMNS", test.columns)
+
+		if got := terminalWidth(); got != test.want {
+			t.Errorf("terminalWidth() with -width %s and COLUMNS=%q is %d, want %d", test.width, test.columns, got, test.want)
+		}
+	}
+
+	// The layouts fit the fallback width rather than collapsing
+	setFlag(t, "width", "0")
+	setEnv(t, "COLUMNS", "")
+
+	response, err := parseResponse("golang", readFixture(t, "golang.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An abstract too long for one line is wrapped to the fallback width
+	response.AbstractText = strings.Repeat("Go is a programming language. ", 10)
+
+	var box bytes.Buffer
+	printAnswerBox(&box, response)
+	for _, line := range strings.Split(strings.Trim(box.String(), "\n"), "\n") {
+		if width := utf8.RuneCountInString(line); width > defaultTerminalWidth || width < defaultTerminalWidth-10 {
+			t.Errorf("the box line %q is %d columns wide, want it to fit %d", line, width, defaultTerminalWidth)
+		}
+	}
+}
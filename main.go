@@ -1,14 +1,20 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // Options specifies all possible API arguments to be passed into the query URL
@@ -18,25 +24,75 @@ type Options struct {
 	NoRedirect   int
 	NoHTML       int
 	SkipDisambig int
+
+	// Region is the locale results are tailored to, such as "us-en", the API default when empty
+	Region string
+
+	// Safe is the safe search level, one of the safeSearchLevels, the API default when empty
+	Safe string
 }
 
 // Response specifies the exact json structure of a generic API query
 // without the fields that we will not be printing to os.Stdout
 type Response struct {
-	AbstractText  string         `json:"AbstractText"`
-	AbstractURL   string         `json:"AbstractURL"`
-	RelatedTopics []RelatedTopic `json:"RelatedTopics"`
+	// Query is the search that produced this response, it is not part of the API payload
+	Query string `json:"-"`
+
+	AbstractSource   string         `json:"AbstractSource"`
+	AbstractText     string         `json:"AbstractText"`
+	AbstractURL      string         `json:"AbstractURL"`
+	Answer           FlexString     `json:"Answer"`
+	AnswerType       FlexString     `json:"AnswerType"`
+	Definition       string         `json:"Definition"`
+	DefinitionSource string         `json:"DefinitionSource"`
+	DefinitionURL    string         `json:"DefinitionURL"`
+	Entity           string         `json:"Entity"`
+	Heading          string         `json:"Heading"`
+	Image            string         `json:"Image"`
+	ImageWidth       FlexInt        `json:"ImageWidth"`
+	ImageHeight      FlexInt        `json:"ImageHeight"`
+	ImageIsLogo      FlexInt        `json:"ImageIsLogo"`
+	Infobox          Infobox        `json:"Infobox"`
+	Redirect         string         `json:"Redirect"`
+	RelatedTopics    []RelatedTopic `json:"RelatedTopics"`
+	Type             string         `json:"Type"`
+	Meta             *Meta          `json:"meta,omitempty"`
+
+	// streamed is set when -stream printed the heading and the primary results, the abstract
+	// among them, before the response was parsed
+	streamed bool
+}
+
+// Meta describes the source the API took the response from, e.g. Wikipedia
+type Meta struct {
+	SrcName string `json:"src_name"`
+	SrcURL  string `json:"src_url"`
 }
 
 // RelatedTopic describes the structure of the underlying map[string]
 // inside of the query response at "RelatedTopics": [{}]
+//
+// An entry is either a single topic, or a named category holding more topics
+// in Topics, which is how disambiguation results group their candidates
 type RelatedTopic struct {
-	FirstURL string `json:"FirstURL"`
-	Text     string `json:"Text"`
+	FirstURL string         `json:"FirstURL"`
+	Text     string         `json:"Text"`
+	Result   string         `json:"Result"`
+	Name     string         `json:"Name,omitempty"`
+	Topics   []RelatedTopic `json:"Topics,omitempty"`
+
+	// hidden counts the topics of a category left out by -max-per-category
+	hidden int
+
+	// number is the position of the topic for -select and :open, shown with -line-numbers
+	number int
+
+	// score is the relevance given to the topic by -rank, shown with -show-scores
+	score int
 }
 
 // TerminalColors is a short list of strings to pass to fmt.Println()
-// to change the color of text in the terminal
+// to change the color of text in the terminal, use color() to respect colorEnabled()
 var TerminalColors = map[string]string{
 	"Reset":  "\033[0m",
 	"Red":    "\033[31m",
@@ -49,147 +105,1050 @@ var TerminalColors = map[string]string{
 // flagSearch and flagHelp define command-line launch flags for running outside of interactive mode,
 // i.e. without a search prompt
 var (
-	flagSearch = flag.String("s", "", "Specifies a search parameter for the DuckDuckGo Instant Answers API.")
-	flagHelp   = flag.Bool("h", false, "Prints command usage information")
-	flagEmpty  = flag.Bool("", false, "When no flags are specified, the program will run in interactive mode.")
+	flagSearch  = flag.String("s", "", "Specifies a search parameter for the DuckDuckGo Instant Answers API.")
+	flagHelp    = flag.Bool("h", false, "Prints command usage information")
+	flagVerbose = flag.Bool("v", false, "Prints diagnostic information to stderr.")
+	flagNoColor = flag.Bool("no-color", false, "Disables colored output, as does setting the NO_COLOR environment variable.")
+	flagJSON    = flag.Bool("json", false, "Prints each result as compact single line JSON, colorized when writing to a terminal.")
+	flagPretty  = flag.Bool("json-pretty", false, "Prints each result as indented JSON, colorized when writing to a terminal.")
+	flagEmpty   = flag.Bool("", false, "When no flags are specified, the program will run in interactive mode.")
+	flagFile    = flag.String("f", "", "Reads queries from the specified file (- for stdin) and runs them in batch mode.")
+	flagDelim   = flag.String("delim", `\n`, "Specifies the delimiter between batch queries: \\n, \\0, \\t or any single character.")
+
+	flagTemplate             = flag.String("template", "", "Formats each result with a text/template string, e.g. '{{.AbstractText}} - {{.AbstractURL}}'.")
+	flagDisambig             = flag.Bool("disambig", false, "Lists the candidate topics of ambiguous queries instead of skipping them.")
+	flagNoRelated            = flag.Bool("no-related", false, "Skips the related topics section of each result.")
+	flagTrace                = flag.String("trace", "", "Records every API request and response to the specified file in a HAR-like JSON format.")
+	flagPrefix               = flag.String("prefix", "", "Prints a label before each result, %q is replaced by the query, e.g. '>>> %q:'.")
+	flagRedact               = flag.String("trace-redact", "Authorization,Proxy-Authorization,Cookie,Set-Cookie", "Comma separated list of headers whose values are redacted in the -trace file.")
+	flagAPIBase              = flag.String("api-base", "https://api.duckduckgo.com/", "Specifies the base URL of the API, e.g. to use a proxy or mirror.")
+	flagCountOnly            = flag.Bool("count-only", false, "Prints only the number of results; with -s the exit code is 3 when nothing was found.")
+	flagOpen                 = flag.Bool("open", false, "Opens the most relevant link of each result in the default browser.")
+	flagRetries              = flag.Int("retries", 0, "Specifies how many times a failed API request is retried.")
+	flagNoDNSRetry           = flag.Bool("no-dns-retry", false, "Disables the automatic retries after a temporary DNS failure.")
+	flagBasicAuth            = flag.String("basic-auth", "", "Sends HTTP Basic credentials, given as user:pass, with every API request.")
+	flagBox                  = flag.Bool("box", false, "Draws a border around the answer and abstract when writing to a terminal.")
+	flagASCII                = flag.Bool("ascii", false, "Uses ASCII characters instead of Unicode box drawing characters for -box.")
+	flagStrict               = flag.Bool("strict", false, "Reports any response fields that are not modeled, printed when combined with -v.")
+	flagStrictFail           = flag.Bool("strict-fail", false, "Like -strict, but fails the query when unmodeled fields are found.")
+	flagColor                = flag.String("color", "auto", "Controls colored output: auto (only on a terminal), always or never.")
+	flagLimit                = flag.Int("limit", 0, "Prints at most this many related topics, 0 prints them all.")
+	flagRelatedOnly          = flag.Bool("related-only", false, "Prints only the URL of each related topic, one per line.")
+	flagNoSummary            = flag.Bool("no-summary", false, "Does not print a summary of the interactive session when it ends.")
+	flagIndent               = flag.String("indent", "\t", "Specifies the indentation of URLs and topics in the output.")
+	flagCompact              = flag.Bool("compact", false, "Removes the blank lines between sections of the output.")
+	flagShortcuts            = flag.Bool("shortcuts", false, "Expands query shortcuts such as 'w:golang' to '!w golang' and 'def word' to 'define word'.")
+	flagAbstractOnly         = flag.Bool("abstract-only", false, "Prints only the abstract text, without colors or labels; with -s the exit code is 3 when there is none.")
+	flagPriority             = flag.String("priority", strings.Join(answerFields, ","), "Comma separated order in which the answer, abstract and definition are printed.")
+	flagMaxPerCategory       = flag.Int("max-per-category", 0, "Prints at most this many topics under each category of related topics, 0 prints them all.")
+	flagFormat               = flag.String("format", "human", "Selects the output format: human, json, json-pretty, markdown or html.")
+	flagTruecolor            = flag.Bool("truecolor", false, "Uses 24-bit colors, the default when COLORTERM is truecolor or 24bit.")
+	flagTimeout              = flag.Duration("timeout", 0, "Cancels each query that takes longer than the duration, e.g. 10s. There is no limit by default.")
+	flagDeadline             = flag.String("deadline", "", "Cancels any query still running at the RFC3339 timestamp, e.g. 2024-05-01T12:00:00Z.")
+	flagAutoRetryEmpty       = flag.Bool("auto-retry-empty", false, "Retries a query that found nothing once, rephrased, e.g. \"what is a monad?\" as \"monad\".")
+	flagRegion               = flag.String("region", "", "Tailors results to a region such as us-en or de-de, wt-wt for no region.")
+	flagSelect               = flag.Int("select", 0, "Prints only the URL of the Nth related topic; with -s the exit code is 3 when there is no such topic.")
+	flagSilent               = flag.Bool("silent", false, "Suppresses all error and diagnostic output, leaving only the exit code to report failures.")
+	flagCompareRegions       = flag.String("compare-regions", "", "Runs the -s query for each of the comma separated regions, e.g. us-en,de-de, and compares the abstracts.")
+	flagSaveFixture          = flag.String("save-fixture", "", "Also writes the raw API response body, indented, to the specified file, e.g. for testdata/.")
+	flagImageInfo            = flag.Bool("image-info", false, "Prints the image of each result with its dimensions and whether it is a logo.")
+	flagSafe                 = flag.String("safe", "", "Sets the safe search level: strict, moderate or off.")
+	flagStream               = flag.Bool("stream", false, "Prints the abstract as soon as it arrives, before the rest of the response has been received.")
+	flagDiff                 = flag.Bool("diff", false, "Compares the results of the two queries given as arguments, e.g. -diff \"query A\" \"query B\".")
+	flagFollow               = flag.Bool("follow", false, "After each answer, also answers its first related topic, up to -follow-depth times.")
+	flagFollowDepth          = flag.Int("follow-depth", 2, "Specifies how many related topics -follow chases in a row.")
+	flagNoAbstractURL        = flag.Bool("no-abstract-url", false, "Skips the \"More info\" link of the abstract.")
+	flagRetriesOnEmpty       = flag.Int("retries-on-empty", 0, "Specifies how many times a query with an empty result is sent again, at most 5.")
+	flagHTML                 = flag.Bool("html", false, "Prints each result as an HTML fragment, the same as -format html.")
+	flagEmptyMessage         = flag.String("empty-message", "No instant answer.", "Specifies the message shown when a query finds nothing, an empty message shows nothing.")
+	flagGroupByDomain        = flag.Bool("group-by-domain", false, "Groups the related topics by the domain of their URL, the most common domain first.")
+	flagMinAbstractLength    = flag.Int("min-abstract-length", 0, "Treats an abstract shorter than this many characters as if there were none.")
+	flagReverse              = flag.Bool("reverse", false, "Prints the related topics in reverse order, the last topic first.")
+	flagMaxQueryLength       = flag.Int("max-query-length", 500, "Rejects queries longer than this many characters, 0 allows any length.")
+	flagPrompt               = flag.String("prompt", "Search: ", "Specifies the interactive prompt, include any trailing space, e.g. 'ddg> '.")
+	flagStats                = flag.Bool("stats", false, "Prints the mean and the 50th, 90th and 99th percentile latency of the queries at the end of a batch run.")
+	flagFailOnEmpty          = flag.Bool("fail-on-empty", false, "Treats a query that finds nothing as an error, exiting with 1 instead of 3.")
+	flagCache                = flag.Bool("cache", false, "Keeps API responses on disk and answers repeated queries from them.")
+	flagCacheDir             = flag.String("cache-dir", "", "Specifies the directory used by -cache, by default a directory in the user's cache directory.")
+	flagCacheTTL             = flag.Duration("cache-ttl", time.Hour, "Specifies how long a cached response is used by -cache, 0 keeps responses forever.")
+	flagCacheCaseInsensitive = flag.Bool("cache-case-insensitive", false, "Lets -cache answer queries that only differ in case with the same response.")
+	flagDebugDump            = flag.Bool("debug-dump", false, "Prints the options, request, response and parsed result of every query to stderr, for bug reports.")
+	flagURLAware             = flag.Bool("url-aware", false, "Searches for the domain of a query that is a URL, e.g. github.com for https://www.github.com/golang/go.")
+	flagColumns              = flag.Int("columns", 1, "Lays out the text of the related topics in this many columns on a terminal, 0 fits as many as the width allows.")
+	flagMetrics              = flag.String("metrics", "", "Writes Prometheus metrics of the queries to the specified file after a batch run.")
+	flagRetryBudget          = flag.Int("retry-budget", 0, "Caps the retries made by all the queries of the run together, 0 is unlimited.")
+	flagMaxCacheAge          = flag.Duration("max-cache-age", 0, "Ignores and refreshes cached responses older than this for this run only, 0 accepts any age within -cache-ttl.")
+	flagSetTitle             = flag.Bool("set-title", false, "Also shows the answer or abstract in the terminal title.")
+	flagLucky                = flag.Bool("lucky", false, "Opens the most relevant URL of the result in the browser instead of printing the result.")
+	flagLogQueries           = flag.String("log-queries", "", "Appends every query, when it was made and whether it found anything to the specified file, as tab separated values.")
+	flagSeparator            = flag.String("separator", "────────────────────", "Specifies the line printed between the results of a batch run, %q is the next query and %n its position, \"\" prints none.")
+	flagValidateURL          = flag.Bool("validate-url", false, "Checks the API URL built for the query, or for a sample query, and exits without sending it.")
+	flagJSONLFull            = flag.Bool("jsonl-full", false, "Prints each whole result and its query as a single line of JSON, the same as -format jsonl.")
+	flagWatchFile            = flag.String("watch-file", "", "Searches for the content of the specified file every time it changes, until interrupted.")
+	flagLineNumbers          = flag.Bool("line-numbers", false, "Numbers the related topics, as counted by -select and :open N.")
+	flagClean                = flag.Bool("clean", true, "Strips leftover HTML tags and collapses stray whitespace in the abstract, -clean=false keeps it as sent.")
+	flagCacheWriters         = flag.Int("cache-writers", 4, "Specifies how many -cache entries may be written at the same time.")
+	flagEcho                 = flag.Bool("echo", false, "Prints the query, as it is sent, on its own line before each result.")
+	flagWidth                = flag.Int("width", 0, "Specifies the width of the terminal in columns for -box and -columns, 0 detects it.")
+	flagJoinLines            = flag.Bool("join-lines", false, "Joins the lines of a query of several lines with spaces, instead of searching for its first line.")
+	flagTopicsFallback       = flag.Bool("topics-fallback", false, "Only prints the related topics of a result without an answer, abstract or definition.")
+	flagAlsoJSON             = flag.String("also-json", "", "Also writes each result as a line of JSON to the specified file.")
+	flagAlsoMarkdown         = flag.String("also-markdown", "", "Also writes each result as Markdown to the specified file.")
+	flagRepeatOnEmpty        = flag.Bool("repeat-on-empty", false, "Searches for the last query again when an empty line is entered in interactive mode.")
+	flagDefine               = flag.String("define", "", "Looks up the definition of the specified word and prints only the definition and its source.")
+	flagMaxRedirects         = flag.Int("max-redirects", 10, "Specifies how many redirects a request may follow before it fails.")
+	flagRank                 = flag.Bool("rank", false, "Orders the related topics by how many of the query's words they contain, the most relevant first.")
+	flagShowScores           = flag.Bool("show-scores", false, "Shows the score given to each related topic by -rank, implies -rank.")
+	flagDumpOptions          = flag.Bool("dump-options", false, "Prints the options and flags in effect, and where each value came from, as JSON to stderr before running.")
+	flagPrefetch             = flag.Bool("prefetch", false, "Fetches the answers to the first related topics into the cache in the background in interactive mode, implies -cache.")
 )
 
-// searchPrompt() prompts the user for DuckDuckGo search query
+// httpClient is used for every API request, its Transport is replaced to add features such as -trace
+var httpClient = &http.Client{CheckRedirect: checkRedirect}
+
+// outputFormatter renders every response, it is chosen from the command-line flags by selectFormatter()
+var outputFormatter Formatter = HumanFormatter{}
+
+// logVerbose() prints a diagnostic message to stderr when running with -v
+func logVerbose(format string, args ...interface{}) {
+	if *flagVerbose {
+		fmt.Fprintf(stderr, "verbose: "+format+"\n", args...)
+	}
+}
+
+// errEmptyInput is returned by searchPrompt() when the user entered nothing
+var errEmptyInput = errors.New("Invalid input")
+
+// searchPrompt() prompts the user for DuckDuckGo search query with -prompt, printed exactly as
+// given so that a trailing space is kept or left out as the user chose
 func searchPrompt() (string, error) {
-	fmt.Print("\nSearch: ")
+	fmt.Fprint(os.Stderr, "\n"+*flagPrompt)
 
-	inputReader := bufio.NewReader(os.Stdin)
-	query, err := inputReader.ReadString('\n')
+	query, err := stdinReader.readLine()
 
 	if err != nil {
 		return "", err
 	}
 
 	if strings.TrimSpace(query) == "" {
-		return "", fmt.Errorf("Invalid input")
+		return "", errEmptyInput
 	}
 
 	return query, nil
 }
 
+// defaultOptions() are the Options every query starts from, before the flags are applied
+func defaultOptions() Options {
+	return Options{
+		Format:       "json",
+		Pretty:       1,
+		NoRedirect:   1,
+		NoHTML:       1,
+		SkipDisambig: 1,
+	}
+}
+
 // getAPIURL() formats and returns a string for querying the DuckDuckGo API with http.Get()
 func getAPIURL(queryString string, options Options) string {
+	// -api-base is checked to be an absolute URL when the program starts
+	apiURL, _ := url.Parse(*flagAPIBase)
+
+	// Keep any parameters that are part of -api-base, such as a mirror's access key
+	params := apiURL.Query()
+	params.Set("q", queryString)
+	params.Set("format", options.Format)
+	params.Set("t", "duckduckgo-answers")
 
-	queryString = url.QueryEscape(queryString)
+	// Options left at zero are the API's defaults and are not sent
+	setFlag := func(name string, value int) {
+		if value != 0 {
+			params.Set(name, strconv.Itoa(value))
+		}
+	}
+	setFlag("pretty", options.Pretty)
+	setFlag("no_redirect", options.NoRedirect)
+	setFlag("no_html", options.NoHTML)
+	setFlag("skip_disambig", options.SkipDisambig)
 
-	return fmt.Sprintf("https://api.duckduckgo.com/?q=%s&format=%s&pretty=%d&no_redirect=%d&no_html=%d&skip_disambig=%d&t=duckduckgo-answers", queryString, options.Format, options.Pretty, options.NoRedirect, options.NoHTML, options.SkipDisambig)
+	if options.Region != "" {
+		params.Set("kl", options.Region)
+	}
+
+	if kp, ok := safeSearchLevels[options.Safe]; ok {
+		params.Set("kp", kp)
+	}
+
+	apiURL.RawQuery = params.Encode()
+
+	return apiURL.String()
 }
 
-func queryAPI(apiURL string) *http.Response {
+func queryAPI(ctx context.Context, apiURL string) (*http.Response, error) {
 
-	response, err := http.Get(apiURL)
+	request, err := newAPIRequest(ctx, apiURL)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	return response
+	response, err := doWithRetries(request)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("Request did not finish in time: %v", err)
+	}
+	if errors.Is(err, errTooManyRedirects) {
+		return nil, explainRedirects(err)
+	}
+	if err != nil {
+		return nil, explainOffline(err)
+	}
+
+	if err := checkJSONResponse(response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
 }
 
-func responseToString(response *http.Response) string {
-	stringResponse := make([]string, 1)
+// newAPIRequest() builds the request for an API URL, carrying the -header and -basic-auth headers
+func newAPIRequest(ctx context.Context, apiURL string) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	scanner := bufio.NewScanner(response.Body)
-	for scanner.Scan() {
-		stringResponse = append(stringResponse, scanner.Text())
+	for name, values := range requestHeaders {
+		for _, value := range values {
+			request.Header.Add(name, value)
+		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		panic(err)
+	return request, nil
+}
+
+// checkJSONResponse() closes and rejects a response that is not JSON. A blocked client is sent
+// an HTML challenge page rather than JSON, which would otherwise surface as a confusing parse error
+func checkJSONResponse(response *http.Response) error {
+	if contentType := response.Header.Get("Content-Type"); !isJSONContentType(contentType) {
+		response.Body.Close()
+		return fmt.Errorf("API returned non-JSON content (%s), you may be rate-limited or blocked", contentType)
 	}
 
-	response.Body.Close()
+	return nil
+}
+
+// isJSONContentType() reports whether a Content-Type header describes JSON. The API labels its
+// JSON as application/x-javascript, and a missing header is given the benefit of the doubt
+func isJSONContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
 
-	return strings.Join(stringResponse[:], "")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return strings.Contains(mediaType, "json") || strings.Contains(mediaType, "javascript")
 }
 
-func unmarshalResponse(jsonInput string) Response {
+func responseToString(response *http.Response) (string, error) {
+	// Read the whole body at once, a line scanner fails on the long single lines of compact responses
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read the API response: %v", err)
+	}
+
+	return string(body), nil
+}
+
+func unmarshalResponse(jsonInput string) (Response, error) {
 	jsonData := Response{}
 
 	jsonBytes := []byte(jsonInput)
 
 	if err := json.Unmarshal(jsonBytes, &jsonData); err != nil {
-		panic(err)
+		return Response{}, fmt.Errorf("Failed to parse the API response: %v", err)
 	}
 
-	return jsonData
+	return jsonData, nil
 }
 
-func printResponse(input Response) {
+func printResponse(out io.Writer, input Response) error {
+	// Stop printing at the first failed write, e.g. once the reader of a pipe has gone away
+	w := &stickyWriter{w: out}
+
+	// Bang queries only return where they would have redirected to
+	if isRedirectOnly(input) {
+		fmt.Fprint(w, blank())
+		fmt.Fprintln(w, color("Green"), "Redirects to:", color("Blue")+input.Redirect+blank())
+		fmt.Fprint(w, color("Reset"))
+		return w.err
+	}
+
+	// -stream has printed the heading and the primary results while the response was arriving
+	if !input.streamed {
+		printHeading(w, input)
+
+		// Boxes are only drawn on a terminal so that piped output stays plain
+		if *flagBox && isTerminal(os.Stdout) {
+			printAnswerBox(w, input)
+		} else {
+			printPrimary(w, input)
+		}
+	}
+
+	if input.AbstractURL != "" && !*flagNoAbstractURL {
+		fmt.Fprintln(w, color("Green"), "More info:")
+		fmt.Fprintln(w, color("Blue"), indent()+input.AbstractURL+blank())
+	}
 
-	fmt.Printf("\n %s \n \n", input.AbstractText)
+	if input.Meta != nil && input.Meta.SrcName != "" {
+		source := input.Meta.SrcName
+		if input.Meta.SrcURL != "" {
+			source += " (" + input.Meta.SrcURL + ")"
+		}
 
-	if input.AbstractURL != "" {
-		fmt.Println(TerminalColors["Green"], "More info:")
-		fmt.Println(TerminalColors["Blue"], "\t"+input.AbstractURL+"\n")
+		fmt.Fprintln(w, color("Green"), "Source:", color("White")+source+blank())
 	}
 
-	fmt.Println(TerminalColors["Green"], "Related topics: ")
+	if *flagImageInfo && input.Image != "" {
+		fmt.Fprintln(w, color("Green"), "Image:")
+		fmt.Fprintln(w, color("Blue"), indent()+imageURL(input.Image))
+		if details := imageDetails(input); details != "" {
+			fmt.Fprintln(w, color("White"), indent()+details)
+		}
+		fmt.Fprint(w, blank())
+	}
 
-	for key := range input.RelatedTopics {
-		fmt.Println(TerminalColors["Blue"], "\t"+input.RelatedTopics[key].FirstURL)
-		fmt.Println(TerminalColors["White"], "\t"+input.RelatedTopics[key].Text+"\n")
+	if showRelated(input) {
+		topics := displayedTopics(input)
+		if *flagGroupByDomain {
+			printTopicsByDomain(w, topics)
+		} else {
+			printRelatedTopics(w, topics)
+		}
 	}
 
 	// Reset the terminal color after we finish printing
-	fmt.Print(TerminalColors["Reset"])
+	fmt.Fprint(w, color("Reset"))
+
+	return w.err
+}
+
+// printHeading() introduces a classified subject with a heading tagged with its entity, e.g. [person]
+func printHeading(w io.Writer, input Response) {
+	if input.Entity == "" {
+		return
+	}
+
+	fmt.Fprint(w, blank())
+	fmt.Fprintln(w, color("Green"), heading(input), color("White")+"["+input.Entity+"]")
+	fmt.Fprint(w, color("Reset"))
+}
+
+// printRelatedTopics() prints the "Related topics" section of a response
+func printRelatedTopics(w io.Writer, topics []RelatedTopic) {
+	fmt.Fprintln(w, color("Green"), "Related topics: ")
+
+	if *flagColumns != 1 && isTerminal(os.Stdout) {
+		printTopicColumns(w, topics)
+		return
+	}
+
+	width := numberWidth(topics)
+
+	for key := range topics {
+		// Categories are printed as a heading above the topics nested inside of them
+		if len(topics[key].Topics) > 0 {
+			fmt.Fprintln(w, color("Green"), indent()+topics[key].Name+":")
+		}
+
+		for _, topic := range flattenTopics(topics[key : key+1]) {
+			label, padding := numberLabel(topic, width)
+			fmt.Fprintln(w, color("Blue"), indent()+label+topic.FirstURL)
+			fmt.Fprintln(w, color("White"), indent()+padding+topic.Text+scoreLabel(topic)+blank())
+		}
+
+		if topics[key].hidden > 0 {
+			fmt.Fprintln(w, color("White"), fmt.Sprintf("%s(%d more in %s)", indent(), topics[key].hidden, topics[key].Name)+blank())
+		}
+	}
+}
+
+// printTopicColumns() prints the text of the related topics in -columns columns, each category
+// under its own heading. Topics without a text are listed by their URL
+func printTopicColumns(w io.Writer, topics []RelatedTopic) {
+	// A tab in -indent takes up to 8 columns, and the line starts with a space
+	width := terminalWidth() - utf8.RuneCountInString(strings.ReplaceAll(indent(), "\t", "        ")) - 1
+
+	numbers := numberWidth(topics)
+	topicCell := func(topic RelatedTopic) string {
+		label, _ := numberLabel(topic, numbers)
+		if topic.Text == "" {
+			return label + topic.FirstURL + scoreLabel(topic)
+		}
+
+		return label + topic.Text + scoreLabel(topic)
+	}
+
+	printCells := func(cells []string) {
+		columns := columnCount(cells, *flagColumns, width)
+		for _, line := range columnRows(layoutColumns(cells, columns)) {
+			fmt.Fprintln(w, color("White"), indent()+line)
+		}
+	}
+
+	cells := []string{}
+	for _, topic := range topics {
+		if len(topic.Topics) == 0 {
+			cells = append(cells, topicCell(topic))
+			continue
+		}
+
+		// Print the topics gathered so far before the category interrupts them
+		printCells(cells)
+		cells = []string{}
+
+		fmt.Fprintln(w, color("Green"), indent()+topic.Name+":")
+
+		nested := []string{}
+		for _, topic := range flattenTopics(topic.Topics) {
+			nested = append(nested, topicCell(topic))
+		}
+		if topic.hidden > 0 {
+			nested = append(nested, fmt.Sprintf("(%d more in %s)", topic.hidden, topic.Name))
+		}
+		printCells(nested)
+	}
+	printCells(cells)
+
+	fmt.Fprint(w, color("Reset")+blank())
+}
+
+// heading() is the title of a response, the name of its subject or otherwise the query
+func heading(input Response) string {
+	if input.Heading != "" {
+		return input.Heading
+	}
+
+	return input.Query
+}
+
+// indent() is placed before indented lines of human readable output, set with -indent
+func indent() string {
+	return *flagIndent
+}
+
+// blank() is the newline that leaves an empty line between sections, it is empty with -compact
+func blank() string {
+	if *flagCompact {
+		return ""
+	}
+
+	return "\n"
+}
+
+// resultCount() is the number reported by -count-only: every related topic, including those
+// nested inside categories, plus one when the response has an abstract, a direct answer or a definition
+func resultCount(input Response) int {
+	count := len(flattenTopics(input.RelatedTopics))
+
+	if input.AbstractText != "" || input.Answer != "" || input.Definition != "" {
+		count++
+	}
+
+	return count
+}
+
+// printQueryLabels() prints the lines that come before a result on stdout: the query itself with
+// -echo, then the -prefix label
+func printQueryLabels(query string) {
+	if *flagEcho {
+		fmt.Fprintln(os.Stdout, query)
+	}
+
+	if *flagPrefix != "" {
+		fmt.Fprintln(os.Stdout, expandPrefix(*flagPrefix, query))
+	}
+}
+
+// expandPrefix() replaces every %q in the -prefix label with the query
+func expandPrefix(prefix string, query string) string {
+	return strings.ReplaceAll(prefix, "%q", query)
+}
+
+func processAPIRequest(query string, options Options) (response Response, err error) {
+	defer recoverPanic(&err)
+
+	response, err = answerQuery(query, options)
+	if err != nil || !*flagFollow {
+		return response, err
+	}
+
+	if err := followRelated(response, options); err != nil {
+		if err == errOutputClosed {
+			return response, err
+		}
+		fmt.Fprintln(stderr, err)
+	}
+
+	return response, nil
+}
+
+// answerQuery() fetches the response to a single query and prints it
+func answerQuery(query string, options Options) (Response, error) {
+	// Only the human readable output prints the heading and the abstract first, so only it can be
+	// streamed, and not when the abstract might still be dropped for being too short
+	var abstracts io.Writer
+	if _, human := outputFormatter.(HumanFormatter); *flagStream && human && !*flagBox && *flagMinAbstractLength == 0 {
+		printQueryLabels(preprocessQuery(query))
+		abstracts = os.Stdout
+	}
+
+	parsedResponse, err := fetchResponse(query, options, abstracts)
+	if err != nil {
+		return Response{}, err
+	}
+
+	// The API occasionally answers with an empty result that a second request fills in
+	for retry := 1; retry <= *flagRetriesOnEmpty && resultCount(parsedResponse) == 0; retry++ {
+		logVerbose("empty result for %q, retrying (%d/%d)", parsedResponse.Query, retry, *flagRetriesOnEmpty)
+		time.Sleep(emptyRetryDelay)
+
+		retried, err := fetchResponse(query, options, nil)
+		if err != nil {
+			return Response{}, err
+		}
+		parsedResponse = retried
+	}
+
+	// Give an empty result one more chance with a rephrased query
+	if *flagAutoRetryEmpty && resultCount(parsedResponse) == 0 {
+		if rephrased := rephraseQuery(parsedResponse.Query); rephrased != "" {
+			logVerbose("no results for %q, retrying as %q", parsedResponse.Query, rephrased)
+
+			retried, err := fetchResponse(rephrased, options, nil)
+			if err == nil && resultCount(retried) > 0 {
+				fmt.Fprintf(stderr, "No results for %q, showing results for %q\n", parsedResponse.Query, retried.Query)
+				parsedResponse = retried
+			}
+		}
+	}
+
+	// Reorder the topics once here so that every output, and :open, sees the same order
+	if *flagRank {
+		parsedResponse.RelatedTopics = rankTopics(parsedResponse.RelatedTopics, parsedResponse.Query)
+	}
+	if *flagReverse {
+		parsedResponse.RelatedTopics = reverseTopics(parsedResponse.RelatedTopics)
+	}
+
+	if abstracts == nil {
+		printQueryLabels(parsedResponse.Query)
+	}
+
+	if err := formatResponse(os.Stdout, parsedResponse); err != nil {
+		if isClosedOutput(err) {
+			return parsedResponse, errOutputClosed
+		}
+
+		// A result without the one part a formatter prints has found nothing, which the exit
+		// code reports, while any other failure to print it fails the query
+		var missing missingError
+		if !errors.As(err, &missing) {
+			return parsedResponse, err
+		}
+		fmt.Fprintln(stderr, err)
+	}
+
+	writeExtraOutputs(parsedResponse)
+
+	if titleEnabled() && !isEmptyResult(parsedResponse) {
+		setTitle(os.Stdout, parsedResponse)
+	}
+
+	// -lucky has opened the link already
+	if _, lucky := outputFormatter.(luckyFormatter); *flagOpen && !lucky {
+		if link := bestURL(parsedResponse); link != "" {
+			if err := openURL(link); err != nil {
+				fmt.Fprintln(stderr, err)
+			}
+		}
+	}
+
+	return parsedResponse, nil
+}
+
+// isEmptyResult() reports whether a response has nothing to show, not even where a bang redirects to
+func isEmptyResult(input Response) bool {
+	return resultCount(input) == 0 && input.Redirect == ""
+}
+
+// formatResponse() prints a response with the outputFormatter. An empty result is the one case
+// all formats share: the human readable output shows -empty-message instead, while the other
+// formats still print their empty output, e.g. valid JSON, with the message going to stderr
+func formatResponse(w io.Writer, input Response) error {
+	if isEmptyResult(input) && *flagEmptyMessage != "" {
+		if _, human := outputFormatter.(HumanFormatter); human {
+			_, err := fmt.Fprintln(w, blank()+" "+*flagEmptyMessage)
+			return err
+		}
+
+		fmt.Fprintln(stderr, *flagEmptyMessage)
+	}
+
+	return outputFormatter.Format(w, input)
+}
+
+// fetchResponse() sends a single query to the API and returns the parsed, normalized response.
+// When abstracts is not nil the heading and abstract are streamed to it as the body arrives, for -stream
+func fetchResponse(query string, options Options, abstracts io.Writer) (Response, error) {
+	if domain, ok := urlDomain(strings.TrimSpace(query)); ok && *flagURLAware {
+		fmt.Fprintf(stderr, "Note: the query is a URL, searching for %q instead\n", domain)
+	}
+
+	if *flagCache {
+		if response, ok := cachedResponse(query, options); ok {
+			sessionStats.recordCacheHit(response)
+			queryLog.record(query, response, nil)
+			return response, nil
+		}
+	}
+
+	start := time.Now()
+	response, err := queryResponse(query, options, abstracts)
+	sessionStats.record(response, err, time.Since(start))
+	queryLog.record(query, response, err)
+
+	return response, err
 }
 
-func processAPIRequest(query string, options Options) {
+func queryResponse(query string, options Options, abstracts io.Writer) (Response, error) {
+	// Trim the input and expand any shortcuts before it is sent
+	query = preprocessQuery(query)
+
+	// Refuse accidental pastes before they become an unwieldy URL
+	if length := utf8.RuneCountInString(query); *flagMaxQueryLength > 0 && length > *flagMaxQueryLength {
+		return Response{}, fmt.Errorf("Query is too long: %d characters, -max-query-length is %d", length, *flagMaxQueryLength)
+	}
+
 	// Encode the users input query into URL format, and return the formatted API url
 	queryURL := getAPIURL(query, options)
 
-	// Use http.Get() to retrieve an HTTP response for our query
-	apiResponse := queryAPI(queryURL)
+	if *flagDebugDump {
+		defer beginDebugDump(stderr, query, options, queryURL)()
+	}
 
-	// Read the response into our buffer reader then combine it into a single string
-	stringAnswer := responseToString(apiResponse)
+	// Send the request, retrying network errors, to retrieve an HTTP response for our query
+	ctx, cancel := queryContext(queryParent)
+	defer cancel()
+
+	apiResponse, err := queryAPI(ctx, queryURL)
+	if err != nil {
+		return Response{}, err
+	}
+
+	// Read the response into our buffer reader then combine it into a single string, or with
+	// -stream print the abstract as soon as it arrives
+	var stringAnswer string
+	streamed := false
+	if abstracts != nil {
+		body, ok, err := streamBody(apiResponse.Body, query, abstracts)
+		apiResponse.Body.Close()
+		if err != nil {
+			return Response{}, err
+		}
+		stringAnswer, streamed = string(body), ok
+	} else {
+		stringAnswer, err = responseToString(apiResponse)
+		if err != nil {
+			return Response{}, err
+		}
+	}
+
+	// Keep a copy of the body exactly as the API sent it, before any processing
+	if *flagSaveFixture != "" {
+		if err := saveFixture(*flagSaveFixture, []byte(stringAnswer)); err != nil {
+			fmt.Fprintln(stderr, err)
+		}
+	}
+
+	parsedResponse, err := parseResponse(query, stringAnswer, streamed)
+	if err != nil {
+		return Response{}, err
+	}
+
+	if *flagDebugDump {
+		dumpParsed(stderr, parsedResponse)
+	}
+
+	// Empty results are not cached, so that -retries-on-empty and later runs ask again
+	if *flagCache && !isEmptyResult(parsedResponse) {
+		writeCache(cacheKey(query, options), stringAnswer)
+	}
+
+	return parsedResponse, nil
+}
+
+// cachedResponse() returns the response to a query from the -cache, if it holds one
+func cachedResponse(query string, options Options) (Response, bool) {
+	query = preprocessQuery(query)
+
+	body, ok := readCache(cacheKey(query, options))
+	if !ok {
+		return Response{}, false
+	}
+
+	response, err := parseResponse(query, body, false)
+	if err != nil {
+		logVerbose("ignoring the cache entry for %q: %v", query, err)
+		return Response{}, false
+	}
+
+	return response, true
+}
+
+// parseResponse() turns the raw body of an API response to query into a Response{}
+func parseResponse(query string, stringAnswer string, streamed bool) (Response, error) {
+	// An empty body, as sent with 204 No Content, is an answer with nothing in it rather than
+	// broken JSON
+	if strings.TrimSpace(stringAnswer) == "" {
+		logVerbose("the API sent an empty body, treating it as an empty result")
+		return Response{Query: query, RelatedTopics: []RelatedTopic{}}, nil
+	}
+
+	// Look for fields the API has started sending that Response{} does not model yet
+	if *flagStrict || *flagStrictFail {
+		unknown, err := unknownFields([]byte(stringAnswer))
+		if err != nil {
+			return Response{}, err
+		}
+
+		for _, field := range unknown {
+			logVerbose("unmodeled field in response, %s", field)
+		}
+
+		if len(unknown) > 0 && *flagStrictFail {
+			return Response{}, fmt.Errorf("Response contains %d unmodeled field(s): %s", len(unknown), strings.Join(unknown, "; "))
+		}
+	}
 
 	// Unmarshal the JSON-encoded string into our Response{} data structure
-	parsedResponse := unmarshalResponse(stringAnswer)
+	parsedResponse, err := unmarshalResponse(stringAnswer)
+	if err != nil {
+		return Response{}, err
+	}
+	parsedResponse.Query = query
+	parsedResponse.streamed = streamed
+	normalizeResponse(&parsedResponse)
+	dropShortAbstract(&parsedResponse, *flagMinAbstractLength)
 
-	// Nicely print the response data
-	printResponse(parsedResponse)
+	return parsedResponse, nil
 }
 
 func main() {
-	queryOptions := &Options{
-		Format:       "json",
-		Pretty:       1,
-		NoRedirect:   1,
-		NoHTML:       1,
-		SkipDisambig: 1,
-	}
+	defaults := defaultOptions()
+	queryOptions := &defaults
 
+	flag.Var(&flagHeaders, "header", "Adds a \"Key: Value\" header to every API request, may be repeated.")
 	flag.Parse()
 
+	// Leave the exit code as the only sign of failure
+	if *flagSilent {
+		stderr = io.Discard
+	}
+
+	// Report writes to a closed pipe as errors rather than being killed by SIGPIPE,
+	// so that printing can stop cleanly
+	ignoreSIGPIPE()
+
+	// Allow the tab escape so that -indent '\t' can be typed without a literal tab
+	*flagIndent = strings.ReplaceAll(*flagIndent, `\t`, "\t")
+
+	priority, err := parsePriority(*flagPriority)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		os.Exit(exitUsage)
+	}
+	answerPriority = priority
+
+	if *flagRetriesOnEmpty < 0 || *flagRetriesOnEmpty > maxEmptyRetries {
+		fmt.Fprintf(stderr, "Invalid -retries-on-empty %d: expected 0 to %d\n", *flagRetriesOnEmpty, maxEmptyRetries)
+		os.Exit(exitUsage)
+	}
+
+	if *flagWidth < 0 {
+		fmt.Fprintf(stderr, "Invalid -width %d: expected a number of columns, or 0 to detect it\n", *flagWidth)
+		os.Exit(exitUsage)
+	}
+
+	if *flagShowScores {
+		implyFlag(flagRank, "rank", "show-scores")
+	}
+
+	if *flagPrefetch {
+		implyFlag(flagCache, "cache", "prefetch")
+	}
+
+	if *flagMaxRedirects < 0 {
+		fmt.Fprintf(stderr, "Invalid -max-redirects %d: expected a number of redirects, or 0 to follow none\n", *flagMaxRedirects)
+		os.Exit(exitUsage)
+	}
+
+	if *flagColumns < 0 {
+		fmt.Fprintf(stderr, "Invalid -columns %d: expected 0 for automatic or a number of columns\n", *flagColumns)
+		os.Exit(exitUsage)
+	}
+
+	deadline, err := parseDeadline(*flagDeadline, *flagTimeout)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		os.Exit(exitUsage)
+	}
+	queryDeadline = deadline
+
+	region, err := normalizeRegion(*flagRegion)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		os.Exit(exitUsage)
+	}
+	queryOptions.Region = region
+
+	safe, err := normalizeSafe(*flagSafe)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		os.Exit(exitUsage)
+	}
+	queryOptions.Safe = safe
+
+	if err := validateColorMode(*flagColor); err != nil {
+		fmt.Fprintln(stderr, err)
+		os.Exit(exitUsage)
+	}
+
+	if apiBase, err := url.Parse(*flagAPIBase); err != nil || apiBase.Scheme == "" || apiBase.Host == "" {
+		fmt.Fprintf(stderr, "Invalid -api-base %q: expected an absolute URL\n", *flagAPIBase)
+		os.Exit(exitUsage)
+	}
+
+	headers, err := buildRequestHeaders(flagHeaders, *flagBasicAuth)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		os.Exit(exitUsage)
+	}
+	requestHeaders = headers
+
+	// Ask the API for disambiguation results so the candidate topics can be listed
+	if *flagDisambig {
+		queryOptions.SkipDisambig = 0
+	}
+
 	// If a help parameter was specified, print usage information
 	if *flagHelp != false {
 		flag.PrintDefaults()
 		os.Exit(-1)
 	}
 
+	if *flagTrace != "" {
+		transport, err := newTraceTransport(http.DefaultTransport, *flagTrace, *flagRedact)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			os.Exit(exitUsage)
+		}
+		httpClient.Transport = transport
+	}
+
+	if *flagDebugDump {
+		next := httpClient.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		httpClient.Transport = debugDumpTransport{next: next, w: stderr}
+	}
+
+	formatter, err := selectFormatter()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		os.Exit(exitUsage)
+	}
+	outputFormatter = formatter
+
+	if err := openExtraOutputs(); err != nil {
+		fmt.Fprintln(stderr, err)
+		os.Exit(exitUsage)
+	}
+
+	// Compare the results of the two queries given as arguments
+	if *flagDiff {
+		if flag.NArg() != 2 {
+			fmt.Fprintln(stderr, "Invalid -diff: expected two queries, e.g. -diff \"query A\" \"query B\"")
+			os.Exit(exitUsage)
+		}
+
+		a, b := diffQueries(flag.Arg(0), flag.Arg(1), *queryOptions)
+		for _, result := range []fetchResult{a, b} {
+			if result.err != nil {
+				fmt.Fprintln(stderr, result.err)
+				os.Exit(exitError)
+			}
+		}
+
+		if err := printDiff(os.Stdout, a.response, b.response); err != nil && !isClosedOutput(err) {
+			fmt.Fprintln(stderr, err)
+			os.Exit(exitError)
+		}
+
+		if resultCount(a.response) == 0 && resultCount(b.response) == 0 {
+			os.Exit(exitNoResults)
+		}
+		os.Exit(exitFound)
+	}
+
+	// Without -s the query may be given as arguments, or through DDG_QUERY when there are no
+	// arguments and no batch input either
+	searchSource := ""
+	if *flagSearch == "" && flag.NArg() > 0 {
+		*flagSearch = strings.Join(flag.Args(), " ")
+		searchSource = "arguments"
+	}
+
+	if *flagSearch == "" && *flagFile == "" && !stdinIsPiped() {
+		*flagSearch = os.Getenv("DDG_QUERY")
+		if *flagSearch != "" {
+			searchSource = "DDG_QUERY"
+		}
+	}
+
+	if *flagDumpOptions {
+		if err := dumpOptions(stderr, *queryOptions, searchSource); err != nil {
+			fmt.Fprintln(stderr, err)
+		}
+	}
+
+	// Check the URL that the query would be sent to, without sending it
+	if *flagValidateURL {
+		query := preprocessQuery(*flagSearch)
+		if query == "" {
+			query = validationQuery
+		}
+
+		apiURL := getAPIURL(query, *queryOptions)
+		problems := validateURL(apiURL, expectedParams(query, *queryOptions))
+		if len(problems) > 0 {
+			fmt.Fprintf(stderr, "Invalid URL %s\n", apiURL)
+			for _, problem := range problems {
+				fmt.Fprintln(stderr, indent()+problem)
+			}
+			os.Exit(exitError)
+		}
+
+		fmt.Fprintf(os.Stdout, "URL ok: %s\n", apiURL)
+		os.Exit(exitFound)
+	}
+
+	// Run the search once per region and compare the results
+	if *flagSearch != "" && *flagCompareRegions != "" {
+		regions, err := parseRegions(*flagCompareRegions)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			os.Exit(exitUsage)
+		}
+
+		results := compareRegions(*flagSearch, regions, *queryOptions)
+		if err := printRegionComparison(os.Stdout, results); err != nil && !isClosedOutput(err) {
+			fmt.Fprintln(stderr, err)
+			os.Exit(exitError)
+		}
+
+		code := exitNoResults
+		for _, result := range results {
+			switch {
+			case result.err != nil:
+				code = exitError
+			case code == exitNoResults && resultCount(result.response) > 0:
+				code = exitFound
+			}
+		}
+		os.Exit(code)
+	}
+
+	// Look up a word and print its definition as a dictionary entry
+	if *flagDefine != "" {
+		response, err := lookupDefinition(*flagDefine, *queryOptions)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			os.Exit(exitError)
+		}
+
+		// Whatever else the API found for the query, only a definition answers it
+		if response.Definition == "" {
+			fmt.Fprintf(stderr, "No definition found for %q\n", strings.TrimSpace(*flagDefine))
+			os.Exit(exitNoResults)
+		}
+
+		if err := printDefinition(os.Stdout, *flagDefine, response); err != nil && !isClosedOutput(err) {
+			fmt.Fprintln(stderr, err)
+			os.Exit(exitError)
+		}
+		os.Exit(exitFound)
+	}
+
+	// Search for the content of a file every time it changes
+	if *flagWatchFile != "" {
+		if err := watchFile(*flagWatchFile, *queryOptions); err != nil {
+			fmt.Fprintln(stderr, err)
+			os.Exit(exitError)
+		}
+		return
+	}
+
 	// If a search parameter was specified at launch, do not run in interactive mode
 	if *flagSearch != "" {
-		processAPIRequest(*flagSearch, *queryOptions)
-		os.Exit(1)
+		response, err := processAPIRequest(*flagSearch, *queryOptions)
+		if err == errOutputClosed {
+			os.Exit(exitFound)
+		}
+
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			os.Exit(exitError)
+		}
+
+		code := searchExitCode(response)
+		if *flagLucky && bestURL(response) == "" {
+			code = exitNoResults
+		}
+		if code == exitNoResults && *flagFailOnEmpty {
+			fmt.Fprintf(stderr, "No results for %q\n", response.Query)
+			code = exitError
+		}
+		os.Exit(code)
 	}
 
-	// Interactive mode, with a search prompt
-	for {
-		// Ask the user for a search query
-		userInput, err := searchPrompt()
+	// If a query file was specified, or queries are piped into stdin, run in batch mode
+	if *flagFile != "" || stdinIsPiped() {
+		delim, err := parseDelimiter(*flagDelim)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			os.Exit(exitUsage)
+		}
 
+		input, err := openBatchInput(*flagFile)
 		if err != nil {
-			fmt.Println(err)
-			continue
+			fmt.Fprintln(stderr, err)
+			os.Exit(exitUsage)
 		}
+		defer input.Close()
 
-		processAPIRequest(userInput, *queryOptions)
+		if err := runBatch(input, delim, *queryOptions); err != nil {
+			fmt.Fprintln(stderr, err)
+			os.Exit(exitError)
+		}
+
+		return
 	}
 
+	// Interactive mode, with a search prompt
+	runInteractive(*queryOptions)
 }
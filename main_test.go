@@ -0,0 +1,743 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// mainEnv is set in the environment of the copies of the test binary started by runMain()
+const mainEnv = "DDG_TEST_MAIN"
+
+func TestMain(m *testing.M) {
+	// A copy started by runMain() runs the program with the arguments after "--" instead of the tests
+	if os.Getenv(mainEnv) == "1" {
+		for i, arg := range os.Args {
+			if arg == "--" {
+				os.Args = append([]string{os.Args[0]}, os.Args[i+1:]...)
+				break
+			}
+		}
+
+		main()
+		os.Exit(exitFound)
+	}
+
+	os.Exit(m.Run())
+}
+
+// runMain() runs the program with the arguments in a copy of the test binary, so that its exit
+// code can be checked, and returns what it printed. The input is piped to it unless it is empty
+func runMain(t *testing.T, input string, args ...string) (string, string, int) {
+	t.Helper()
+
+	command := exec.Command(os.Args[0], append([]string{"-test.run=^$", "--"}, args...)...)
+	command.Env = append(os.Environ(), mainEnv+"=1", "NO_COLOR=1")
+	if input != "" {
+		command.Stdin = strings.NewReader(input)
+	}
+
+	var stdout, stderr bytes.Buffer
+	command.Stdout, command.Stderr = &stdout, &stderr
+
+	err := command.Run()
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		return stdout.String(), stderr.String(), exitErr.ExitCode()
+	case err != nil:
+		t.Fatal(err)
+	}
+
+	return stdout.String(), stderr.String(), exitFound
+}
+
+// setFlag() sets a command-line flag for the duration of a test
+func setFlag(t *testing.T, name string, value string) {
+	t.Helper()
+
+	previous := flag.Lookup(name).Value.String()
+	if err := flag.Set(name, value); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { flag.Set(name, previous) })
+}
+
+// setEnv() sets an environment variable for the duration of a test, it is inherited by the
+// programs started by runMain()
+func setEnv(t *testing.T, name string, value string) {
+	t.Helper()
+
+	previous, had := os.LookupEnv(name)
+	os.Setenv(name, value)
+
+	t.Cleanup(func() {
+		if had {
+			os.Setenv(name, previous)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+// discardFormatter renders nothing, for tests of answerQuery() that only look at the response
+type discardFormatter struct{}
+
+func (discardFormatter) Format(io.Writer, Response) error {
+	return nil
+}
+
+// quietOutput() silences the results and diagnostics printed by answerQuery() for the duration
+// of a test
+func quietOutput(t *testing.T) {
+	formatter, diagnostics := outputFormatter, stderr
+	outputFormatter, stderr = discardFormatter{}, io.Discard
+
+	t.Cleanup(func() { outputFormatter, stderr = formatter, diagnostics })
+}
+
+// captureStdout() returns what run() prints to os.Stdout
+func captureStdout(t *testing.T, run func()) string {
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = previous }()
+
+	printed := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		reader.Close()
+		printed <- string(data)
+	}()
+
+	run()
+	writer.Close()
+
+	return <-printed
+}
+
+// readFixture() returns the content of a response body saved under testdata
+func readFixture(tb testing.TB, name string) string {
+	tb.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return string(data)
+}
+
+// renderFixture() parses a response body saved under testdata and returns its default output
+func renderFixture(t *testing.T, name string) string {
+	t.Helper()
+
+	response, err := parseResponse("golang", readFixture(t, name), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := printResponse(&out, response); err != nil {
+		t.Fatal(err)
+	}
+
+	return out.String()
+}
+
+// apiServer() starts a stub of the API answering with handler, and points -api-base at it for
+// the duration of a test
+func apiServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	setFlag(t, "api-base", server.URL+"/")
+
+	return server
+}
+
+// serveJSON() answers every request with body, labelled as the API labels its JSON
+func serveJSON(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-javascript")
+		io.WriteString(w, body)
+	}
+}
+
+// stubAPI() starts a stub of the API answering with handler, and returns the -api-base pointing
+// at it, for the programs started by runMain()
+func stubAPI(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return server.URL + "/"
+}
+
+// closedAPI() returns an -api-base that refuses connections, the port of a stub that has been shut down
+func closedAPI(t *testing.T) string {
+	t.Helper()
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	return server.URL + "/"
+}
+
+// fixtureServer() is stubAPI() answering every request with a fixture
+func fixtureServer(t *testing.T, name string) string {
+	t.Helper()
+
+	return stubAPI(t, serveJSON(readFixture(t, name)))
+}
+
+func TestGetAPIURL(t *testing.T) {
+	setFlag(t, "api-base", "https://mirror.example.com/api?key=abc")
+
+	tests := []struct {
+		name    string
+		query   string
+		options Options
+		want    string
+	}{
+		{
+			"every option",
+			"C++ & Go",
+			Options{Format: "json", Pretty: 1, NoRedirect: 1, NoHTML: 1, SkipDisambig: 1, Region: "us-en", Safe: "strict"},
+			"https://mirror.example.com/api?format=json&key=abc&kl=us-en&kp=1&no_html=1&no_redirect=1&pretty=1&q=C%2B%2B+%26+Go&skip_disambig=1&t=duckduckgo-answers",
+		},
+		{
+			"defaults left out",
+			"golang",
+			Options{Format: "json"},
+			"https://mirror.example.com/api?format=json&key=abc&q=golang&t=duckduckgo-answers",
+		},
+		{
+			"moderate safe search",
+			"golang",
+			Options{Format: "json", Safe: "moderate"},
+			"https://mirror.example.com/api?format=json&key=abc&kp=-1&q=golang&t=duckduckgo-answers",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := getAPIURL(test.query, test.options); got != test.want {
+				t.Errorf("getAPIURL(%q) =\n%s\nwant\n%s", test.query, got, test.want)
+			}
+		})
+	}
+}
+
+func TestQueryResponseLongLine(t *testing.T) {
+	// The fixture is a single line of JSON, far longer than the 64KB token limit of a bufio.Scanner
+	body := readFixture(t, "large.json")
+	if len(body) <= 64*1024 {
+		t.Fatalf("the fixture is only %d bytes", len(body))
+	}
+
+	apiServer(t, serveJSON(body))
+
+	response, err := queryResponse("golang", defaultOptions(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := len(flattenTopics(response.RelatedTopics)), 1200; got != want {
+		t.Errorf("got %d related topics, want %d", got, want)
+	}
+}
+
+func TestQueryResponseNonJSON(t *testing.T) {
+	apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, "<html><body>Please verify you are a human</body></html>")
+	})
+
+	_, err := queryResponse("golang", defaultOptions(), nil)
+	if err == nil {
+		t.Fatal("an HTML page was accepted as a response")
+	}
+
+	if want := "API returned non-JSON content (text/html; charset=utf-8), you may be rate-limited or blocked"; err.Error() != want {
+		t.Errorf("got the error %q, want %q", err, want)
+	}
+}
+
+func TestEmptyBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"204 No Content", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }},
+		{"empty 200", serveJSON("")},
+		{"whitespace only", serveJSON(" \n\t\n")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api := stubAPI(t, test.handler)
+
+			// Nothing was found, which is not a failure
+			stdout, stderr, code := runMain(t, "", "-api-base", api, "-s", "golang")
+			if code != exitNoResults || stdout != "\n No instant answer.\n" || stderr != "" {
+				t.Errorf("exited with %d and printed %q, %q, want %d and the empty result", code, stdout, stderr, exitNoResults)
+			}
+
+			stdout, stderr, _ = runMain(t, "", "-api-base", api, "-json", "-s", "golang")
+			var response Response
+			if err := json.Unmarshal([]byte(stdout), &response); err != nil || !isEmptyResult(response) {
+				t.Errorf("-json printed %q, %q: %v", stdout, stderr, err)
+			}
+		})
+	}
+}
+
+func TestIsJSONContentType(t *testing.T) {
+	for contentType, want := range map[string]bool{
+		"application/x-javascript":        true,
+		"application/json; charset=utf-8": true,
+		"":                                true,
+		"text/html":                       false,
+		"text/plain; charset=utf-8":       false,
+	} {
+		if got := isJSONContentType(contentType); got != want {
+			t.Errorf("isJSONContentType(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
+
+// benchmarkFixtures are the response bodies the benchmarks run over: a typical answer, and one
+// with hundreds of related topics and categories to stress the related topics loop
+var benchmarkFixtures = []string{"golang.json", "large.json"}
+
+func BenchmarkUnmarshalResponse(b *testing.B) {
+	for _, name := range benchmarkFixtures {
+		body := readFixture(b, name)
+
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(body)))
+
+			for i := 0; i < b.N; i++ {
+				if _, err := unmarshalResponse(body); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPrintResponse(b *testing.B) {
+	for _, name := range benchmarkFixtures {
+		response, err := unmarshalResponse(readFixture(b, name))
+		if err != nil {
+			b.Fatal(err)
+		}
+		normalizeResponse(&response)
+
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := printResponse(io.Discard, response); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestNoRelated(t *testing.T) {
+	if out := renderFixture(t, "golang.json"); !strings.Contains(out, "Related topics:") {
+		t.Fatalf("the fixture has no related topics to hide:\n%s", out)
+	}
+
+	setFlag(t, "no-related", "true")
+
+	response, err := parseResponse("golang", readFixture(t, "golang.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"human", "markdown", "html"} {
+		var out bytes.Buffer
+		if err := formatters[name].Format(&out, response); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, topic := range []string{"Related topics", "Rob_Pike", "Limbo"} {
+			if strings.Contains(out.String(), topic) {
+				t.Errorf("the %s output with -no-related has %q:\n%s", name, topic, out.String())
+			}
+		}
+
+		if !strings.Contains(out.String(), "Go is a statically typed") {
+			t.Errorf("the %s output with -no-related lost the abstract:\n%s", name, out.String())
+		}
+	}
+}
+
+func TestExpandPrefix(t *testing.T) {
+	for prefix, want := range map[string]string{
+		">>> %q:":   ">>> rob pike:",
+		"%q vs %q":  "rob pike vs rob pike",
+		"no query":  "no query",
+		"100% sure": "100% sure",
+	} {
+		if got := expandPrefix(prefix, "rob pike"); got != want {
+			t.Errorf("expandPrefix(%q) = %q, want %q", prefix, got, want)
+		}
+	}
+}
+
+func TestPrefixLabelsEachResult(t *testing.T) {
+	apiBase := fixtureServer(t, "golang.json")
+
+	stdout, stderr, code := runMain(t, "golang\nrob pike\n", "-api-base", apiBase, "-prefix", ">>> %q:", "-abstract-only")
+	if code != exitFound {
+		t.Fatalf("exited with %d: %s", code, stderr)
+	}
+
+	abstract := "Go is a statically typed, compiled programming language designed at Google."
+	if want := ">>> golang:\n" + abstract + "\n>>> rob pike:\n" + abstract + "\n"; stdout != want {
+		t.Errorf("printed\n%s\nwant\n%s", stdout, want)
+	}
+}
+
+func TestEcho(t *testing.T) {
+	apiBase := fixtureServer(t, "golang.json")
+	abstract := "Go is a statically typed, compiled programming language designed at Google."
+
+	// The query is echoed as it is sent, before any -prefix label
+	stdout, stderr, code := runMain(t, "  golang \nrob pike\n", "-api-base", apiBase, "-echo", "-prefix", ">>> %q:", "-abstract-only")
+	if code != exitFound {
+		t.Fatalf("exited with %d: %s", code, stderr)
+	}
+
+	if want := "golang\n>>> golang:\n" + abstract + "\nrob pike\n>>> rob pike:\n" + abstract + "\n"; stdout != want {
+		t.Errorf("printed\n%s\nwant\n%s", stdout, want)
+	}
+
+	if stdout, _, _ := runMain(t, "", "-api-base", apiBase, "-echo", "-s", "golang"); !strings.HasPrefix(stdout, "golang\n") {
+		t.Errorf("the human readable output does not start with the query:\n%s", stdout)
+	}
+
+	if stdout, _, _ := runMain(t, "", "-api-base", apiBase, "-abstract-only", "-s", "golang"); stdout != abstract+"\n" {
+		t.Errorf("without -echo printed %q", stdout)
+	}
+}
+
+func TestCountOnly(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+		code int
+	}{
+		// 4 related topics, 2 of them in a category, and the abstract
+		{"populated", readFixture(t, "golang.json"), "5\n", exitFound},
+		{"answer only", `{"Answer":"2","RelatedTopics":[]}`, "1\n", exitFound},
+		{"empty", `{"AbstractText":"","RelatedTopics":[]}`, "0\n", exitNoResults},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			apiBase := stubAPI(t, serveJSON(test.body))
+
+			stdout, stderr, code := runMain(t, "", "-api-base", apiBase, "-s", "golang", "-count-only")
+			if stdout != test.want || code != test.code {
+				t.Errorf("printed %q and exited with %d, want %q and %d: %s", stdout, code, test.want, test.code, stderr)
+			}
+		})
+	}
+}
+
+func TestSourceLine(t *testing.T) {
+	for _, test := range []struct {
+		fixture string
+		want    string
+	}{
+		{"meta.json", "Source: Wikipedia (https://en.wikipedia.org)"},
+		{"answer-object.json", "Source: Calculator\n"},
+		{"define.json", ""},
+	} {
+		out := renderFixture(t, test.fixture)
+		source := strings.Contains(out, "Source:")
+
+		switch {
+		case test.want == "" && source:
+			t.Errorf("%s has no meta block but printed a source:\n%s", test.fixture, out)
+		case test.want != "" && !strings.Contains(out, test.want):
+			t.Errorf("%s printed\n%s\nwithout %q", test.fixture, out, test.want)
+		}
+	}
+}
+
+func TestIndentCompact(t *testing.T) {
+	api := fixtureServer(t, "golang.json")
+
+	run := func(args ...string) string {
+		t.Helper()
+
+		stdout, stderr, code := runMain(t, "", append(append([]string{"-api-base", api}, args...), "golang")...)
+		if code != exitFound || stderr != "" {
+			t.Fatalf("%q exited with %d and printed %q", args, code, stderr)
+		}
+
+		return stdout
+	}
+
+	standard := run()
+	if !strings.Contains(standard, "\n \thttps://duckduckgo.com/Rob_Pike\n") || !strings.Contains(standard, "\n\n") {
+		t.Fatalf("the default output is not indented with a tab and split by blank lines:\n%s", standard)
+	}
+
+	// The typed escape is the same as the default tab
+	if escaped := run("-indent", `\t`); escaped != standard {
+		t.Errorf("-indent '\\t' printed\n%s\nwant\n%s", escaped, standard)
+	}
+
+	dashed := run("-indent", "-> ")
+	if want := strings.ReplaceAll(standard, " \t", " -> "); dashed != want {
+		t.Errorf("-indent '-> ' printed\n%s\nwant\n%s", dashed, want)
+	}
+
+	compact := run("-compact")
+	for _, line := range strings.Split(strings.TrimSuffix(compact, "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			t.Errorf("-compact left a blank line:\n%s", compact)
+			break
+		}
+	}
+
+	// Only the blank lines go, the sections are the same
+	if got, want := strings.Fields(compact), strings.Fields(standard); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("-compact changed the content of the output:\n%s", compact)
+	}
+}
+
+func TestMaxPerCategory(t *testing.T) {
+	api := fixtureServer(t, "categories.json")
+
+	stdout, stderr, code := runMain(t, "", "-api-base", api, "-max-per-category", "2", "unix")
+	if code != exitFound || stderr != "" {
+		t.Fatalf("-max-per-category 2 exited with %d and printed %q", code, stderr)
+	}
+
+	// The topics outside of categories and the categories under the cap are not touched
+	for _, kept := range []string{"Bell Labs American research company", "Ken Thompson", "Dennis Ritchie", "Linux", "Bourne shell", "C shell"} {
+		if !strings.Contains(stdout, "\t"+kept+"\n") {
+			t.Errorf("-max-per-category 2 left out %q:\n%s", kept, stdout)
+		}
+	}
+
+	for _, hidden := range []string{"Brian Kernighan", "Doug McIlroy", "KornShell"} {
+		if strings.Contains(stdout, hidden) {
+			t.Errorf("-max-per-category 2 kept %q:\n%s", hidden, stdout)
+		}
+	}
+
+	for _, note := range []string{"(2 more in People)", "(1 more in Shells)"} {
+		if !strings.Contains(stdout, "\t"+note+"\n") {
+			t.Errorf("-max-per-category 2 did not note %q:\n%s", note, stdout)
+		}
+	}
+
+	if strings.Contains(stdout, "more in Descendants") {
+		t.Errorf("a category under the cap got a note:\n%s", stdout)
+	}
+
+	// Without the flag every topic prints
+	stdout, _, _ = runMain(t, "", "-api-base", api, "unix")
+	if !strings.Contains(stdout, "Doug McIlroy") || strings.Contains(stdout, "more in") {
+		t.Errorf("without -max-per-category the output is\n%s", stdout)
+	}
+
+	_, stderr, code = runMain(t, "", "-api-base", api, "-max-per-category", "two", "unix")
+	if code != exitUsage || !strings.Contains(stderr, `invalid value "two" for flag -max-per-category`) {
+		t.Errorf("-max-per-category two exited with %d and printed %q, want %d", code, stderr, exitUsage)
+	}
+}
+
+func TestNoAbstractURL(t *testing.T) {
+	api := fixtureServer(t, "golang.json")
+	// Markdown escapes the parentheses of the URL, so only its start is looked for
+	link := "https://en.wikipedia.org/wiki/Go_"
+
+	for _, format := range []string{"human", "markdown", "html"} {
+		standard, _, _ := runMain(t, "", "-api-base", api, "-format", format, "golang")
+		if !strings.Contains(standard, link) {
+			t.Fatalf("-format %s does not print the abstract URL:\n%s", format, standard)
+		}
+
+		stdout, stderr, code := runMain(t, "", "-api-base", api, "-format", format, "-no-abstract-url", "golang")
+		if code != exitFound || stderr != "" {
+			t.Fatalf("-format %s -no-abstract-url exited with %d and printed %q", format, code, stderr)
+		}
+
+		if strings.Contains(stdout, link) || strings.Contains(stdout, "More info") {
+			t.Errorf("-format %s -no-abstract-url printed the URL:\n%s", format, stdout)
+		}
+
+		// The abstract and the related topics stay
+		for _, kept := range []string{"Go is a statically typed", "https://duckduckgo.com/Rob_Pike"} {
+			if !strings.Contains(stdout, kept) {
+				t.Errorf("-format %s -no-abstract-url left out %q:\n%s", format, kept, stdout)
+			}
+		}
+	}
+}
+
+func TestQueryPrecedence(t *testing.T) {
+	var mutex sync.Mutex
+	queries := []string{}
+	api := stubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		queries = append(queries, r.URL.Query().Get("q"))
+		mutex.Unlock()
+
+		serveJSON(readFixture(t, "golang.json"))(w, r)
+	})
+
+	setEnv(t, "DDG_QUERY", "from the environment")
+
+	tests := []struct {
+		input string
+		args  []string
+		want  string
+	}{
+		{"", []string{"-s", "from -s"}, "from -s"},
+		{"", []string{"-s", "from -s", "ignored"}, "from -s"},
+		{"", []string{"from", "arguments"}, "from arguments"},
+		{"from stdin\n", nil, "from stdin"},
+		{"", nil, "from the environment"},
+	}
+
+	for _, test := range tests {
+		mutex.Lock()
+		queries = queries[:0]
+		mutex.Unlock()
+
+		_, stderr, code := runMain(t, test.input, append([]string{"-api-base", api}, test.args...)...)
+		if code != exitFound || stderr != "" {
+			t.Errorf("%q exited with %d and printed %q", test.args, code, stderr)
+		}
+
+		mutex.Lock()
+		if len(queries) != 1 || queries[0] != test.want {
+			t.Errorf("%q with DDG_QUERY set sent %q, want %q", test.args, queries, test.want)
+		}
+		mutex.Unlock()
+	}
+
+	// -dump-options names where the query came from
+	_, stderr, _ := runMain(t, "", "-api-base", api, "-dump-options")
+	if !strings.Contains(stderr, `"DDG_QUERY"`) {
+		t.Errorf("-dump-options does not name DDG_QUERY as the source:\n%s", stderr)
+	}
+}
+
+func TestEmptyMessage(t *testing.T) {
+	api := fixtureServer(t, "empty.json")
+
+	run := func(args ...string) (string, string) {
+		t.Helper()
+
+		stdout, stderr, code := runMain(t, "", append(append([]string{"-api-base", api}, args...), "-s", "xyzzy")...)
+		if code != exitNoResults {
+			t.Errorf("%q exited with %d, want %d", args, code, exitNoResults)
+		}
+
+		return stdout, stderr
+	}
+
+	if stdout, stderr := run(); stdout != "\n No instant answer.\n" || stderr != "" {
+		t.Errorf("an empty result printed %q, %q", stdout, stderr)
+	}
+
+	if stdout, _ := run("-empty-message", "Nothing found."); stdout != "\n Nothing found.\n" {
+		t.Errorf("-empty-message printed %q", stdout)
+	}
+
+	if stdout, stderr := run("-empty-message", ""); strings.Contains(stdout, "No instant answer.") || stderr != "" {
+		t.Errorf("an empty -empty-message printed %q, %q", stdout, stderr)
+	}
+
+	// The other formats keep stdout to their own output and show the message on stderr
+	stdout, stderr := run("-json", "-empty-message", "Nothing found.")
+	var response Response
+	if err := json.Unmarshal([]byte(stdout), &response); err != nil || response.AbstractText != "" || len(response.RelatedTopics) != 0 {
+		t.Errorf("-json printed %q for an empty result: %v", stdout, err)
+	}
+	if stderr != "Nothing found.\n" {
+		t.Errorf("-json printed %q to stderr for an empty result", stderr)
+	}
+
+	if stdout, stderr := run("-abstract-only"); stdout != "" || stderr != "No instant answer.\n" {
+		t.Errorf("-abstract-only printed %q, %q for an empty result", stdout, stderr)
+	}
+
+	_, stderr, code := runMain(t, "", "-api-base", api, "-fail-on-empty", "-s", "xyzzy")
+	if code != exitError || !strings.Contains(stderr, `No results for "xyzzy"`) {
+		t.Errorf("-fail-on-empty exited with %d and printed %q, want %d", code, stderr, exitError)
+	}
+}
+
+func TestReverse(t *testing.T) {
+	api := fixtureServer(t, "golang.json")
+
+	run := func(args ...string) string {
+		t.Helper()
+
+		stdout, stderr, code := runMain(t, "", append(append([]string{"-api-base", api, "-related-only"}, args...), "-s", "golang")...)
+		if code != exitFound || stderr != "" {
+			t.Fatalf("%q exited with %d and printed %q", args, code, stderr)
+		}
+
+		return stdout
+	}
+
+	reversed := func(output string) string {
+		lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+		for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+			lines[i], lines[j] = lines[j], lines[i]
+		}
+
+		return strings.Join(lines, "\n") + "\n"
+	}
+
+	// The topics inside the category are reversed too, so the last topic of all comes first
+	want := "https://en.wikipedia.org/wiki/Limbo\nhttps://duckduckgo.com/C_(programming_language)\nhttps://duckduckgo.com/Ken_Thompson\nhttps://duckduckgo.com/Rob_Pike\n"
+	if got := run("-reverse"); got != want || reversed(run()) != want {
+		t.Errorf("-reverse printed\n%s\nwant\n%s", got, want)
+	}
+
+	// -reverse applies after -rank
+	if ranked := run("-rank"); run("-rank", "-reverse") != reversed(ranked) {
+		t.Errorf("-rank -reverse is not the -rank order reversed:\n%s", run("-rank", "-reverse"))
+	}
+
+	// -select counts in the printed order
+	stdout, _, code := runMain(t, "", "-api-base", api, "-reverse", "-select", "1", "-s", "golang")
+	if code != exitFound || stdout != "https://en.wikipedia.org/wiki/Limbo\n" {
+		t.Errorf("-reverse -select 1 exited with %d and printed %q", code, stdout)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricsPrefix is prepended to the name of every metric written by -metrics
+const metricsPrefix = "duckduckgo_answers_"
+
+// latencyBuckets are the upper bounds, in seconds, of the latency histogram written by -metrics
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// writeMetrics() writes the counters and latencies of a run to path in the Prometheus text
+// exposition format, for -metrics. The file is rewritten as a whole, as expected by the
+// textfile collector of the node exporter
+func writeMetrics(path string, s statsSnapshot) error {
+	if err := os.WriteFile(path, []byte(formatMetrics(s)), 0644); err != nil {
+		return fmt.Errorf("Failed to write -metrics: %v", err)
+	}
+
+	logVerbose("wrote the metrics of the run to %s", path)
+	return nil
+}
+
+// formatMetrics() renders the counters as Prometheus counters and the latencies as a histogram
+func formatMetrics(s statsSnapshot) string {
+	var out strings.Builder
+
+	counter := func(name string, help string, value int) {
+		fmt.Fprintf(&out, "# HELP %s%s %s\n", metricsPrefix, name, help)
+		fmt.Fprintf(&out, "# TYPE %s%s counter\n", metricsPrefix, name)
+		fmt.Fprintf(&out, "%s%s %d\n", metricsPrefix, name, value)
+	}
+
+	counter("queries_total", "Queries made, including those answered from the cache.", s.Queries)
+	counter("errors_total", "Queries that failed.", s.Errors)
+	counter("empty_results_total", "Queries that found nothing.", s.Empty)
+	counter("cache_hits_total", "Queries answered from the cache without asking the API.", s.CacheHits)
+
+	name := metricsPrefix + "query_duration_seconds"
+	fmt.Fprintf(&out, "# HELP %s Time taken by each query of the API.\n", name)
+	fmt.Fprintf(&out, "# TYPE %s histogram\n", name)
+
+	// Buckets are cumulative, each one counts the queries at least as fast as its bound
+	var sum time.Duration
+	for _, latency := range s.Latencies {
+		sum += latency
+	}
+
+	for _, bound := range latencyBuckets {
+		count := 0
+		for _, latency := range s.Latencies {
+			if latency.Seconds() <= bound {
+				count++
+			}
+		}
+
+		fmt.Fprintf(&out, "%s_bucket{le=\"%s\"} %d\n", name, strconv.FormatFloat(bound, 'g', -1, 64), count)
+	}
+
+	fmt.Fprintf(&out, "%s_bucket{le=\"+Inf\"} %d\n", name, len(s.Latencies))
+	fmt.Fprintf(&out, "%s_sum %s\n", name, strconv.FormatFloat(sum.Seconds(), 'g', -1, 64))
+	fmt.Fprintf(&out, "%s_count %d\n", name, len(s.Latencies))
+
+	return out.String()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	metricComment = regexp.MustCompile(`^# (HELP|TYPE) ([a-zA-Z_:][a-zA-Z0-9_:]*) (.+)$`)
+	metricSample  = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)(\{[a-zA-Z_][a-zA-Z0-9_]*="[^"]*"\})? (\S+)$`)
+)
+
+// parseMetrics() checks text against the Prometheus text exposition format and returns the value
+// of each sample by its name and labels. Every sample must belong to a family declared by a TYPE
+// line before it, and the buckets of a histogram must be cumulative and end with +Inf
+func parseMetrics(t *testing.T, text string) map[string]float64 {
+	t.Helper()
+
+	if !strings.HasSuffix(text, "\n") {
+		t.Fatalf("the metrics do not end with a line break:\n%s", text)
+	}
+
+	types := map[string]string{}
+	samples := map[string]float64{}
+	lastBucket := map[string]float64{}
+
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if match := metricComment.FindStringSubmatch(line); match != nil {
+			if match[1] == "TYPE" {
+				types[match[2]] = match[3]
+			}
+			continue
+		}
+
+		match := metricSample.FindStringSubmatch(line)
+		if match == nil {
+			t.Fatalf("invalid metrics line %q", line)
+		}
+
+		value, err := strconv.ParseFloat(match[3], 64)
+		if err != nil {
+			t.Fatalf("invalid value in %q: %v", line, err)
+		}
+
+		family := match[1]
+		if kind := types[strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(family, "_bucket"), "_sum"), "_count")]; kind == "histogram" {
+			family = strings.TrimSuffix(family, "_bucket")
+			if strings.HasSuffix(match[1], "_bucket") {
+				if value < lastBucket[family] {
+					t.Errorf("the buckets of %s are not cumulative at %q", family, line)
+				}
+				lastBucket[family] = value
+			}
+		} else if kind == "" {
+			t.Errorf("the sample %q has no TYPE", line)
+		}
+
+		samples[match[1]+match[2]] = value
+	}
+
+	for family := range lastBucket {
+		if samples[family+`_bucket{le="+Inf"}`] != samples[family+"_count"] {
+			t.Errorf("the +Inf bucket of %s is not its count", family)
+		}
+	}
+
+	return samples
+}
+
+func TestFormatMetrics(t *testing.T) {
+	snapshot := statsSnapshot{
+		Queries:   4,
+		Errors:    1,
+		Empty:     1,
+		CacheHits: 1,
+		Latencies: []time.Duration{30 * time.Millisecond, 200 * time.Millisecond, 3 * time.Second},
+	}
+
+	samples := parseMetrics(t, formatMetrics(snapshot))
+
+	want := map[string]float64{
+		"duckduckgo_answers_queries_total":                            4,
+		"duckduckgo_answers_errors_total":                             1,
+		"duckduckgo_answers_empty_results_total":                      1,
+		"duckduckgo_answers_cache_hits_total":                         1,
+		`duckduckgo_answers_query_duration_seconds_bucket{le="0.05"}`: 1,
+		`duckduckgo_answers_query_duration_seconds_bucket{le="0.25"}`: 2,
+		`duckduckgo_answers_query_duration_seconds_bucket{le="2.5"}`:  2,
+		`duckduckgo_answers_query_duration_seconds_bucket{le="5"}`:    3,
+		`duckduckgo_answers_query_duration_seconds_bucket{le="+Inf"}`: 3,
+		"duckduckgo_answers_query_duration_seconds_sum":               3.23,
+		"duckduckgo_answers_query_duration_seconds_count":             3,
+	}
+
+	for name, value := range want {
+		if got, ok := samples[name]; !ok || got != value {
+			t.Errorf("%s = %v, want %v", name, got, value)
+		}
+	}
+}
+
+func TestMetricsFile(t *testing.T) {
+	api := stubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("q") {
+		case "golang":
+			serveJSON(readFixture(t, "golang.json"))(w, r)
+		case "xyzzy":
+			serveJSON(readFixture(t, "empty.json"))(w, r)
+		default:
+			http.Error(w, "unavailable", http.StatusNotFound)
+		}
+	})
+
+	path := filepath.Join(t.TempDir(), "ddg.prom")
+	_, stderr, code := runMain(t, "golang\nxyzzy\nbroken\n", "-api-base", api, "-metrics", path, "-abstract-only", "-empty-message", "")
+	if code != exitError {
+		t.Errorf("the batch exited with %d, want %d: %s", code, exitError, stderr)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := parseMetrics(t, string(data))
+	if samples["duckduckgo_answers_queries_total"] != 3 || samples["duckduckgo_answers_errors_total"] != 1 || samples["duckduckgo_answers_empty_results_total"] != 1 {
+		t.Errorf("-metrics wrote\n%s", data)
+	}
+
+	_, stderr, code = runMain(t, "golang\n", "-api-base", api, "-metrics", filepath.Join(t.TempDir(), "missing", "ddg.prom"))
+	if code != exitError || !strings.Contains(stderr, "Failed to write -metrics: ") {
+		t.Errorf("-metrics to a missing directory exited with %d and printed %q", code, stderr)
+	}
+}
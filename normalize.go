@@ -0,0 +1,101 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// normalizeResponse() repairs the parts of a parsed response that the API sends in an awkward form
+func normalizeResponse(input *Response) {
+	input.RelatedTopics = normalizeTopics(input.RelatedTopics)
+
+	if *flagClean {
+		input.AbstractText = cleanText(input.AbstractText)
+	}
+}
+
+// htmlTag matches what looks like an opening or closing HTML tag, e.g. <b> or </a>, but not a
+// lone comparison such as "x < y"
+var htmlTag = regexp.MustCompile(`</?[a-zA-Z][^<>]*>`)
+
+// cleanText() repairs text that still carries markup despite no_html, for -clean. Leftover tags are
+// stripped with stripTags(), which also decodes entities, and runs of whitespace such as stray
+// line breaks are collapsed into single spaces
+func cleanText(text string) string {
+	if htmlTag.MatchString(text) {
+		return stripTags(text)
+	}
+
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// dropShortAbstract() empties an abstract of fewer than min characters, for -min-abstract-length,
+// so that a stub such as "Go may refer to:" is handled as if there were no abstract
+func dropShortAbstract(input *Response, min int) {
+	if input.AbstractText == "" || utf8.RuneCountInString(input.AbstractText) >= min {
+		return
+	}
+
+	logVerbose("dropping the abstract %q, it is shorter than -min-abstract-length %d", input.AbstractText, min)
+	input.AbstractText = ""
+}
+
+// normalizeTopics() recovers the text and link of topics that only carry them as HTML in Result,
+// e.g. <a href="https://duckduckgo.com/Rob_Pike">Rob Pike</a> Canadian programmer
+func normalizeTopics(topics []RelatedTopic) []RelatedTopic {
+	for i := range topics {
+		topic := &topics[i]
+
+		if topic.Text == "" && topic.Result != "" {
+			topic.Text = stripTags(topic.Result)
+		}
+
+		if topic.FirstURL == "" && topic.Result != "" {
+			topic.FirstURL = firstHref(topic.Result)
+		}
+
+		topic.Topics = normalizeTopics(topic.Topics)
+	}
+
+	return topics
+}
+
+// stripTags() is a minimal HTML tag stripper: it drops everything between < and >, decodes
+// entities such as &amp; and collapses the remaining whitespace. It is not an HTML parser and
+// is only meant for the short snippets of markup found in API responses
+func stripTags(markup string) string {
+	var text strings.Builder
+
+	inTag := false
+	for _, r := range markup {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+			text.WriteRune(' ')
+		case !inTag:
+			text.WriteRune(r)
+		}
+	}
+
+	return strings.Join(strings.Fields(html.UnescapeString(text.String())), " ")
+}
+
+// firstHref() returns the target of the first link in a snippet of HTML, or an empty string
+func firstHref(markup string) string {
+	start := strings.Index(markup, `href="`)
+	if start < 0 {
+		return ""
+	}
+
+	start += len(`href="`)
+	end := strings.Index(markup[start:], `"`)
+	if end < 0 {
+		return ""
+	}
+
+	return html.UnescapeString(markup[start : start+end])
+}
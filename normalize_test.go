@@ -0,0 +1,127 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResultOnlyTopics(t *testing.T) {
+	response, err := parseResponse("plan 9", readFixture(t, "result-only.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topics := flattenTopics(response.RelatedTopics)
+	want := []RelatedTopic{
+		{FirstURL: "https://duckduckgo.com/Inferno_(operating_system)", Text: "Inferno A successor of Plan 9 & a distributed OS"},
+		{FirstURL: "https://duckduckgo.com/Rob_Pike", Text: "Rob Pike Canadian programmer"},
+	}
+
+	if len(topics) != len(want) {
+		t.Fatalf("the fixture has %d topics, want %d", len(topics), len(want))
+	}
+
+	for i, topic := range topics {
+		if topic.FirstURL != want[i].FirstURL || topic.Text != want[i].Text {
+			t.Errorf("topic %d is %q, %q, want %q, %q", i+1, topic.FirstURL, topic.Text, want[i].FirstURL, want[i].Text)
+		}
+	}
+
+	out := renderFixture(t, "result-only.json")
+	for _, topic := range want {
+		if !strings.Contains(out, indent()+topic.FirstURL+"\n") || !strings.Contains(out, indent()+topic.Text+"\n") {
+			t.Errorf("the output does not list %q:\n%s", topic.Text, out)
+		}
+	}
+
+	if strings.Contains(out, "<a href") {
+		t.Errorf("the markup of Result was printed:\n%s", out)
+	}
+}
+
+func TestDropShortAbstract(t *testing.T) {
+	tests := []struct {
+		abstract string
+		min      int
+		want     string
+	}{
+		// Five runes in six bytes, the length is counted in runes
+		{"Gödel", 5, "Gödel"},
+		{"Gödel", 6, ""},
+		{"Gödel", 0, "Gödel"},
+		{"", 10, ""},
+	}
+
+	for _, test := range tests {
+		response := Response{AbstractText: test.abstract}
+		dropShortAbstract(&response, test.min)
+
+		if response.AbstractText != test.want {
+			t.Errorf("dropShortAbstract(%q, %d) left %q, want %q", test.abstract, test.min, response.AbstractText, test.want)
+		}
+	}
+}
+
+func TestMinAbstractLength(t *testing.T) {
+	api := fixtureServer(t, "golang.json")
+	abstract := "Go is a statically typed, compiled programming language designed at Google."
+
+	stdout, stderr, code := runMain(t, "", "-api-base", api, "-abstract-only", "-min-abstract-length", "20", "-s", "golang")
+	if code != exitFound || stdout != abstract+"\n" || stderr != "" {
+		t.Errorf("-min-abstract-length 20 exited with %d and printed %q, %q", code, stdout, stderr)
+	}
+
+	// A dropped abstract is as good as none
+	stdout, _, code = runMain(t, "", "-api-base", api, "-abstract-only", "-min-abstract-length", "200", "-s", "golang")
+	if code != exitNoResults || stdout != "" {
+		t.Errorf("-abstract-only -min-abstract-length 200 exited with %d and printed %q, want %d", code, stdout, exitNoResults)
+	}
+
+	// The rest of the result is still printed
+	stdout, _, code = runMain(t, "", "-api-base", api, "-min-abstract-length", "200", "-s", "golang")
+	if code != exitFound || strings.Contains(stdout, abstract) || !strings.Contains(stdout, "https://duckduckgo.com/Rob_Pike") {
+		t.Errorf("-min-abstract-length 200 exited with %d and printed\n%s", code, stdout)
+	}
+
+	_, stderr, code = runMain(t, "", "-api-base", api, "-min-abstract-length", "long", "-s", "golang")
+	if code != exitUsage || !strings.Contains(stderr, `invalid value "long" for flag -min-abstract-length`) {
+		t.Errorf("-min-abstract-length long exited with %d and printed %q, want %d", code, stderr, exitUsage)
+	}
+}
+
+func TestCleanAbstract(t *testing.T) {
+	raw := "<b>Plan 9 from Bell Labs</b> is a distributed operating system\n\t  from <i>Bell Labs</i> as a successor to Unix &amp; Research Unix."
+
+	tests := []struct {
+		clean string
+		want  string
+	}{
+		{"true", "Plan 9 from Bell Labs is a distributed operating system from Bell Labs as a successor to Unix & Research Unix."},
+		{"false", raw},
+	}
+
+	for _, test := range tests {
+		t.Run("-clean="+test.clean, func(t *testing.T) {
+			setFlag(t, "clean", test.clean)
+
+			response, err := parseResponse("plan 9", readFixture(t, "markup.json"), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if response.AbstractText != test.want {
+				t.Errorf("the abstract is %q, want %q", response.AbstractText, test.want)
+			}
+		})
+	}
+
+	// The cleaned abstract is what gets printed
+	if out := renderFixture(t, "markup.json"); strings.ContainsAny(out, "<>") || strings.Contains(out, "&amp;") {
+		t.Errorf("markup was left in the output:\n%s", out)
+	}
+
+	// Text without tags only has its whitespace collapsed, a comparison is not a tag
+	if got := cleanText("x < y\n  and  y > z &amp; more"); got != "x < y and y > z &amp; more" {
+		t.Errorf("cleanText() = %q", got)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	// offlineProbeHost is resolved to tell a mistyped -api-base apart from having no network at all
+	offlineProbeHost    = "duckduckgo.com"
+	offlineProbeTimeout = time.Second
+)
+
+// explainOffline() replaces a connection error with a plain explanation when the machine appears
+// to have no network, e.g. in airplane mode. The check is best-effort: an unreachable network is
+// taken as offline straight away, while a DNS failure is only blamed on being offline when a quick
+// lookup of a well known host fails as well
+func explainOffline(err error) error {
+	if !appearsOffline(err) {
+		return err
+	}
+
+	return fmt.Errorf("You appear to be offline. Check your network connection and try again. (%v)", err)
+}
+
+func appearsOffline(err error) bool {
+	if isUnreachable(err) {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), offlineProbeTimeout)
+	defer cancel()
+
+	_, probeErr := net.DefaultResolver.LookupHost(ctx, offlineProbeHost)
+	return probeErr != nil
+}
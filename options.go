@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// optionEnvVars are the environment variables that change a setting, listed by -dump-options
+// when they are set
+var optionEnvVars = []string{"DDG_QUERY", "NO_COLOR", "COLORTERM", "COLUMNS"}
+
+// impliedFlags maps the boolean flags turned on by another flag to the name of that flag, e.g.
+// -show-scores implies -rank, so that -dump-options can say where their value came from
+var impliedFlags = map[string]string{}
+
+// implyFlag() turns on the boolean flag name because the flag by needs it
+func implyFlag(value *bool, name string, by string) {
+	*value = true
+	impliedFlags[name] = by
+}
+
+// redactedFlags are the flags whose values carry credentials, -dump-options prints them with
+// the secret part replaced by redactedValue, as -trace does for headers
+var redactedFlags = map[string]func(string) string{
+	"basic-auth": redactBasicAuth,
+	"header":     redactHeaders,
+}
+
+// redactBasicAuth() keeps the user of a "user:pass" -basic-auth value and hides the password
+func redactBasicAuth(value string) string {
+	colon := strings.Index(value, ":")
+	if colon < 0 {
+		return value
+	}
+
+	return value[:colon+1] + redactedValue
+}
+
+// redactHeaders() keeps the names of the -header values and hides their values, any of which
+// may be a token
+func redactHeaders(string) string {
+	redacted := []string{}
+	for _, header := range flagHeaders {
+		name, _, err := parseHeader(header)
+		if err != nil {
+			name = header
+		}
+		redacted = append(redacted, name+": "+redactedValue)
+	}
+
+	return strings.Join(redacted, ", ")
+}
+
+// dumpedFlag is a flag as listed by -dump-options, with where its value came from: "flag" when
+// it was given on the command line, "implied by -name" when another flag turned it on,
+// "default" otherwise, or for -s "arguments" or "DDG_QUERY"
+type dumpedFlag struct {
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// dumpedOptions is the JSON written by -dump-options
+type dumpedOptions struct {
+	Options     Options               `json:"options"`
+	Flags       map[string]dumpedFlag `json:"flags"`
+	Environment map[string]string     `json:"environment"`
+}
+
+// dumpOptions() writes the settings in effect once the flags have been parsed and checked: the
+// Options sent with every query, the value of every flag and the environment variables that
+// change a setting. searchSource is where -s came from when it was not given as a flag
+func dumpOptions(w io.Writer, options Options, searchSource string) error {
+	dump := dumpedOptions{
+		Options:     options,
+		Flags:       map[string]dumpedFlag{},
+		Environment: map[string]string{},
+	}
+
+	flag.VisitAll(func(f *flag.Flag) {
+		// The unnamed flag only documents the interactive mode in the usage text
+		if f.Name == "" {
+			return
+		}
+		dump.Flags[f.Name] = dumpedFlag{Value: flagValue(f), Source: "default"}
+	})
+
+	for name, by := range impliedFlags {
+		dump.Flags[name] = dumpedFlag{Value: flagValue(flag.Lookup(name)), Source: "implied by -" + by}
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		dump.Flags[f.Name] = dumpedFlag{Value: flagValue(f), Source: "flag"}
+	})
+
+	if searchSource != "" {
+		dump.Flags["s"] = dumpedFlag{Value: *flagSearch, Source: searchSource}
+	}
+
+	for _, name := range optionEnvVars {
+		if value, ok := os.LookupEnv(name); ok {
+			dump.Environment[name] = value
+		}
+	}
+
+	data, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to write -dump-options: %v", err)
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// flagValue() is the value of a flag as listed by -dump-options, with any credentials redacted
+func flagValue(f *flag.Flag) string {
+	value := f.Value.String()
+	if redact, ok := redactedFlags[f.Name]; ok && value != "" {
+		return redact(value)
+	}
+
+	return value
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDumpOptions(t *testing.T) {
+	setFlag(t, "region", "us-en")
+	setFlag(t, "basic-auth", "user:secret")
+	setFlag(t, "show-scores", "true")
+
+	headers := flagHeaders
+	flagHeaders = stringList{"X-Api-Key: abc123"}
+	t.Cleanup(func() { flagHeaders = headers })
+
+	rank := *flagRank
+	t.Cleanup(func() {
+		*flagRank = rank
+		delete(impliedFlags, "rank")
+	})
+	implyFlag(flagRank, "rank", "show-scores")
+
+	options := defaultOptions()
+	options.Region = "us-en"
+
+	var out bytes.Buffer
+	if err := dumpOptions(&out, options, "DDG_QUERY"); err != nil {
+		t.Fatal(err)
+	}
+
+	var dump dumpedOptions
+	if err := json.Unmarshal(out.Bytes(), &dump); err != nil {
+		t.Fatalf("the dump is not valid JSON: %v\n%s", err, out.String())
+	}
+
+	if dump.Options.Region != "us-en" {
+		t.Errorf("the options hold the region %q, want us-en", dump.Options.Region)
+	}
+
+	for name, want := range map[string]dumpedFlag{
+		"region":      {Value: "us-en", Source: "flag"},
+		"safe":        {Value: "", Source: "default"},
+		"rank":        {Value: "true", Source: "implied by -show-scores"},
+		"show-scores": {Value: "true", Source: "flag"},
+		"s":           {Value: *flagSearch, Source: "DDG_QUERY"},
+		"basic-auth":  {Value: "user:" + redactedValue, Source: "flag"},
+	} {
+		if got := dump.Flags[name]; got != want {
+			t.Errorf("-%s is listed as %+v, want %+v", name, got, want)
+		}
+	}
+
+	// -header is only registered by main(), its values are redacted by the same table
+	if got, want := redactedFlags["header"](flagHeaders.String()), "X-Api-Key: "+redactedValue; got != want {
+		t.Errorf("-header is listed as %q, want %q", got, want)
+	}
+
+	for _, secret := range []string{"secret", "abc123"} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("the dump shows the credential %q", secret)
+		}
+	}
+}
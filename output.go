@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// errOutputClosed is returned once the reader of our output has gone away, as with "| head",
+// so that callers stop cleanly instead of reporting a write error
+var errOutputClosed = errors.New("output closed")
+
+// stickyWriter remembers the first error returned by the underlying writer and discards every
+// write after it, so a long series of prints can be checked for failure once at the end
+type stickyWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (s *stickyWriter) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	n, err := s.w.Write(p)
+	s.err = err
+
+	return n, err
+}
+
+// isClosedOutput() reports whether a write failed because the other end of the output was closed
+func isClosedOutput(err error) bool {
+	return isBrokenPipe(err) || errors.Is(err, io.ErrClosedPipe) || errors.Is(err, os.ErrClosed)
+}
+
+// stderr receives every error and diagnostic message, it discards them when running with -silent
+var stderr io.Writer = os.Stderr
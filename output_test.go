@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestClosedOutput(t *testing.T) {
+	apiServer(t, serveJSON(readFixture(t, "golang.json")))
+
+	// A pipe whose reader has gone away, as with "| head -1" once head has its line
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader.Close()
+	defer writer.Close()
+
+	previous, diagnostics := os.Stdout, stderr
+	var printed bytes.Buffer
+	os.Stdout, stderr = writer, &printed
+	t.Cleanup(func() { os.Stdout, stderr = previous, diagnostics })
+
+	if _, err := answerQuery("golang", defaultOptions()); err != errOutputClosed {
+		t.Errorf("got the error %v, want %v", err, errOutputClosed)
+	}
+
+	if printed.Len() > 0 {
+		t.Errorf("printed %q, want nothing on stderr", printed.String())
+	}
+}
+
+// TestClosedOutputExit checks the whole program: a write to the closed pipe on stdout must not
+// kill it with SIGPIPE, and it exits as if the output had been read
+func TestClosedOutputExit(t *testing.T) {
+	apiBase := fixtureServer(t, "golang.json")
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader.Close()
+	defer writer.Close()
+
+	var stderr bytes.Buffer
+	command := exec.Command(os.Args[0], "-test.run=^$", "--", "-api-base", apiBase, "-s", "golang")
+	command.Env = append(os.Environ(), mainEnv+"=1")
+	command.Stdout, command.Stderr = writer, &stderr
+
+	err = command.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		t.Errorf("exited with %v, want %d", exitErr, exitFound)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	if stderr.Len() > 0 {
+		t.Errorf("printed %q, want nothing on stderr", stderr.String())
+	}
+}
+
+func TestSilent(t *testing.T) {
+	apiBase := closedAPI(t)
+
+	_, stderr, code := runMain(t, "", "-api-base", apiBase, "-s", "golang", "-retries", "0")
+	if code != exitError || stderr == "" {
+		t.Fatalf("without -silent exited with %d and printed %q, want %d and the error", code, stderr, exitError)
+	}
+
+	stdout, stderr, code := runMain(t, "", "-api-base", apiBase, "-s", "golang", "-silent", "-v")
+	if code != exitError {
+		t.Errorf("exited with %d, want %d", code, exitError)
+	}
+
+	if stdout != "" || stderr != "" {
+		t.Errorf("printed %q and %q, want nothing with -silent", stdout, stderr)
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// recoverPanic() turns a panic in the middle of a query into an error, so that one bad response
+// fails a single query instead of the whole program. Deferred with a pointer to the named error
+// result of the function it guards, the stack of the panic is printed with -v
+func recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		logVerbose("recovered from a panic: %v\n%s", r, debug.Stack())
+		*err = fmt.Errorf("Internal error: %v", r)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// panickyFormatter panics for the query "boom" and prints the heading of any other result
+type panickyFormatter struct{}
+
+func (panickyFormatter) Format(w io.Writer, r Response) error {
+	if r.Query == "boom" {
+		panic("the formatter exploded")
+	}
+
+	_, err := fmt.Fprintln(w, r.Heading)
+	return err
+}
+
+func TestRecoverPanic(t *testing.T) {
+	apiServer(t, serveJSON(readFixture(t, "golang.json")))
+	setFlag(t, "v", "true")
+
+	formatter, diagnostics := outputFormatter, stderr
+	var logged bytes.Buffer
+	outputFormatter, stderr = panickyFormatter{}, &logged
+	t.Cleanup(func() { outputFormatter, stderr = formatter, diagnostics })
+
+	var err error
+	captureStdout(t, func() { _, err = processAPIRequest("boom", defaultOptions()) })
+
+	if err == nil || err.Error() != "Internal error: the formatter exploded" {
+		t.Errorf("processAPIRequest() returned %v after a panic", err)
+	}
+
+	// The stack of the panic is there for -v
+	if !strings.Contains(logged.String(), "verbose: recovered from a panic: the formatter exploded\n") || !strings.Contains(logged.String(), "panickyFormatter") {
+		t.Errorf("-v printed\n%s", logged.String())
+	}
+}
+
+func TestRecoverPanicSession(t *testing.T) {
+	apiServer(t, serveJSON(readFixture(t, "golang.json")))
+	setFlag(t, "no-summary", "true")
+
+	formatter := outputFormatter
+	outputFormatter = panickyFormatter{}
+	t.Cleanup(func() { outputFormatter = formatter })
+
+	// The session goes back to the prompt and answers the next query
+	stdout, stderr := runSession(t, strings.NewReader("boom\ngolang\n:quit\n"))
+
+	if !strings.Contains(stderr, "Internal error: the formatter exploded\n") {
+		t.Errorf("the panic was not reported:\n%s", stderr)
+	}
+	if stdout != "Go (programming language)\n" {
+		t.Errorf("the query after the panic printed %q", stdout)
+	}
+}
+
+func TestRecoverPanicWorker(t *testing.T) {
+	results := fetchAll(3, func(i int) (Response, error) {
+		if i == 1 {
+			var topics []RelatedTopic
+			return Response{Heading: topics[i].Text}, nil
+		}
+
+		return Response{Heading: fmt.Sprint(i)}, nil
+	})
+
+	if results[0].err != nil || results[2].err != nil || results[2].response.Heading != "2" {
+		t.Errorf("the queries next to the one that panicked returned %+v", results)
+	}
+	if err := results[1].err; err == nil || !strings.HasPrefix(err.Error(), "Internal error: runtime error: index out of range") {
+		t.Errorf("the query that panicked returned %v", err)
+	}
+}
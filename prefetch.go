@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+const (
+	// prefetchTopics is how many related topics of an answer -prefetch fetches the answers to
+	prefetchTopics = 3
+
+	// prefetchWorkers bounds the prefetch requests sent at the same time, to go easy on the API
+	prefetchWorkers = 2
+)
+
+// prefetchRelated() fetches the answers to the first related topics of a response into the
+// -cache in the background, so that searching for one of them next is answered straight away.
+// The returned function cancels the prefetches still running and waits for them to stop
+func prefetchRelated(response Response, options Options) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, prefetchWorkers)
+
+	for _, query := range prefetchQueries(response, options) {
+		wg.Add(1)
+		go func(query string) {
+			defer wg.Done()
+
+			select {
+			case workers <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-workers }()
+
+			prefetch(ctx, query, options)
+		}(query)
+	}
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// prefetchQueries() returns the queries of the first prefetchTopics related topics of a response
+// that are not the response's own query and are not in the cache already
+func prefetchQueries(response Response, options Options) []string {
+	queries := []string{}
+	seen := map[string]bool{strings.ToLower(response.Query): true}
+
+	for _, topic := range flattenTopics(response.RelatedTopics) {
+		if len(queries) == prefetchTopics {
+			break
+		}
+
+		query := preprocessQuery(topicQuery(topic))
+		if query == "" || seen[strings.ToLower(query)] {
+			continue
+		}
+		seen[strings.ToLower(query)] = true
+
+		if _, cached := freshCacheEntry(cacheKey(query, options)); cached {
+			continue
+		}
+
+		queries = append(queries, query)
+	}
+
+	return queries
+}
+
+// prefetch() fetches the answer to a single query for the cache only. Nothing is printed or
+// counted in the session's summary, a failure is only logged
+func prefetch(ctx context.Context, query string, options Options) (err error) {
+	defer recoverPanic(&err)
+
+	err = prefetchIntoCache(ctx, query, options)
+	switch {
+	case ctx.Err() != nil:
+		logVerbose("cancelled the prefetch of %q", query)
+	case err != nil:
+		logVerbose("failed to prefetch %q: %v", query, err)
+	default:
+		logVerbose("prefetched %q", query)
+	}
+
+	return err
+}
+
+// prefetchIntoCache() sends a single request for the query, under the same -timeout or -deadline
+// as every query, and writes a non-empty answer to the cache. It has none of the extras of a
+// search: it is not retried, so it takes nothing from the -retry-budget, and -save-fixture and
+// -debug-dump leave it out, as they are about the queries the user runs
+func prefetchIntoCache(ctx context.Context, query string, options Options) error {
+	queryCtx, cancel := queryContext(context.WithValue(ctx, prefetchKey{}, true))
+	defer cancel()
+
+	request, err := newAPIRequest(queryCtx, getAPIURL(query, options))
+	if err != nil {
+		return err
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+
+	if err := checkJSONResponse(response); err != nil {
+		return err
+	}
+
+	body, err := responseToString(response)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := parseResponse(query, body, false)
+	if err != nil {
+		return err
+	}
+
+	if !isEmptyResult(parsed) {
+		writeCache(cacheKey(query, options), body)
+	}
+
+	return nil
+}
+
+// prefetchKey marks the context of a prefetch request, see isPrefetch()
+type prefetchKey struct{}
+
+// isPrefetch() reports whether a request was sent by prefetchIntoCache()
+func isPrefetch(ctx context.Context) bool {
+	return ctx.Value(prefetchKey{}) != nil
+}
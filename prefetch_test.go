@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// prefetchResponse() is an answer with related topics to prefetch, from the golang fixture
+func prefetchResponse(t *testing.T) Response {
+	response, err := parseResponse("golang", readFixture(t, "golang.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return response
+}
+
+func TestPrefetchFillsCache(t *testing.T) {
+	setFlag(t, "cache", "true")
+	setFlag(t, "cache-dir", t.TempDir())
+
+	apiServer(t, serveJSON(readFixture(t, "golang.json")))
+
+	response := prefetchResponse(t)
+	queries := prefetchQueries(response, defaultOptions())
+	if len(queries) != prefetchTopics {
+		t.Fatalf("got %d queries to prefetch, want %d: %q", len(queries), prefetchTopics, queries)
+	}
+
+	stop := prefetchRelated(response, defaultOptions())
+	defer stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for _, query := range queries {
+		for {
+			if _, ok := cachedResponse(query, defaultOptions()); ok {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%q was not prefetched into the cache", query)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// The cached topics are skipped, the next prefetch moves on to the ones after them
+	for _, left := range prefetchQueries(response, defaultOptions()) {
+		for _, query := range queries {
+			if left == query {
+				t.Errorf("%q would be prefetched again", query)
+			}
+		}
+	}
+}
+
+func TestPrefetchCancel(t *testing.T) {
+	setFlag(t, "cache", "true")
+	setFlag(t, "cache-dir", t.TempDir())
+
+	// The stub holds every request until it is cancelled
+	var running, most int32
+	apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		now := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+
+		for {
+			seen := atomic.LoadInt32(&most)
+			if now <= seen || atomic.CompareAndSwapInt32(&most, seen, now) {
+				break
+			}
+		}
+
+		<-r.Context().Done()
+	})
+
+	response := prefetchResponse(t)
+	stop := prefetchRelated(response, defaultOptions())
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&running) < prefetchWorkers {
+		if time.Now().After(deadline) {
+			t.Fatal("the prefetches did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelling the prefetches did not stop them")
+	}
+
+	if got := atomic.LoadInt32(&most); got > prefetchWorkers {
+		t.Errorf("%d prefetches ran at once, want at most %d", got, prefetchWorkers)
+	}
+
+	for _, topic := range flattenTopics(response.RelatedTopics) {
+		if _, ok := cachedResponse(topicQuery(topic), defaultOptions()); ok {
+			t.Errorf("the cancelled prefetch of %q was cached", topicQuery(topic))
+		}
+	}
+}
+
+func TestPrefetchLeavesSearchOutputs(t *testing.T) {
+	setFlag(t, "cache", "true")
+	setFlag(t, "cache-dir", t.TempDir())
+	setFlag(t, "debug-dump", "true")
+
+	fixture := filepath.Join(t.TempDir(), "fixture.json")
+	setFlag(t, "save-fixture", fixture)
+
+	var dump bytes.Buffer
+	previous := httpClient.Transport
+	httpClient.Transport = debugDumpTransport{next: http.DefaultTransport, w: &dump}
+	t.Cleanup(func() { httpClient.Transport = previous })
+
+	apiServer(t, serveJSON(readFixture(t, "golang.json")))
+
+	if err := prefetch(context.Background(), "Rob Pike", defaultOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := cachedResponse("Rob Pike", defaultOptions()); !ok {
+		t.Error("the prefetched answer was not cached")
+	}
+
+	if dump.Len() > 0 {
+		t.Errorf("the prefetch was dumped by -debug-dump:\n%s", dump.String())
+	}
+
+	if _, err := os.Stat(fixture); !os.IsNotExist(err) {
+		t.Errorf("the prefetch wrote the -save-fixture file: %v", err)
+	}
+}
+
+func TestPrefetchNotRetried(t *testing.T) {
+	setFlag(t, "retries", "3")
+	setFlag(t, "retry-budget", "10")
+
+	previous := sessionRetries
+	sessionRetries = &retryBudget{}
+	t.Cleanup(func() { sessionRetries = previous })
+
+	var requests int32
+	apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		// Drop the connection, a network error that a search would retry
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	})
+
+	if err := prefetch(context.Background(), "Rob Pike", defaultOptions()); err == nil {
+		t.Fatal("the prefetch succeeded without an answer")
+	}
+
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("the prefetch sent %d requests, want 1", got)
+	}
+
+	if sessionRetries.used != 0 {
+		t.Errorf("the prefetch took %d retries from the -retry-budget", sessionRetries.used)
+	}
+}
+
+func TestPrefetchDeadline(t *testing.T) {
+	setFlag(t, "cache", "true")
+	setFlag(t, "cache-dir", t.TempDir())
+
+	previous := queryDeadline
+	queryDeadline = time.Now().Add(-time.Minute)
+	t.Cleanup(func() { queryDeadline = previous })
+
+	apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+
+	start := time.Now()
+	err := prefetch(context.Background(), "Rob Pike", defaultOptions())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got the error %v, want the prefetch to miss the -deadline", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the prefetch ran for %v past the -deadline", elapsed)
+	}
+
+	if _, ok := cachedResponse("Rob Pike", defaultOptions()); ok {
+		t.Error("a prefetch past the -deadline was cached")
+	}
+}
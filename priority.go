@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// answerFields are the fields that can be shown as the primary result, in the default -priority order
+var answerFields = []string{"answer", "abstract", "definition"}
+
+// answerPriority is the order the primary result fields are printed in, set with -priority
+var answerPriority = answerFields
+
+// parsePriority() parses the comma separated -priority list. Fields left out of the list keep
+// their default relative order after the listed ones, so that nothing is hidden by a short list
+func parsePriority(value string) ([]string, error) {
+	order := []string{}
+	seen := map[string]bool{}
+
+	for _, field := range strings.Split(value, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "" {
+			continue
+		}
+
+		if !isAnswerField(field) {
+			return nil, fmt.Errorf("Invalid -priority field %q: expected one of %s", field, strings.Join(answerFields, ", "))
+		}
+
+		if seen[field] {
+			return nil, fmt.Errorf("Invalid -priority: %q is listed more than once", field)
+		}
+
+		seen[field] = true
+		order = append(order, field)
+	}
+
+	for _, field := range answerFields {
+		if !seen[field] {
+			order = append(order, field)
+		}
+	}
+
+	return order, nil
+}
+
+func isAnswerField(field string) bool {
+	for _, valid := range answerFields {
+		if field == valid {
+			return true
+		}
+	}
+
+	return false
+}
+
+// primaryText() returns the label and text of one of the answerFields, the abstract has no label
+func primaryText(input Response, field string) (string, string) {
+	switch field {
+	case "answer":
+		return "Answer", string(input.Answer)
+	case "definition":
+		return "Definition", input.Definition
+	default:
+		return "", input.AbstractText
+	}
+}
+
+// printAbstract() prints the abstract as it has been printed since the first version, credited
+// to its source, e.g. "— via Wikipedia", when the API names one
+func printAbstract(w io.Writer, text string, source string) {
+	if text != "" && source != "" {
+		text += " " + color("White") + "— via " + source + color("Reset")
+	}
+
+	fmt.Fprint(w, blank())
+	fmt.Fprintf(w, " %s \n", text)
+	if !*flagCompact {
+		fmt.Fprint(w, " \n")
+	}
+}
+
+// printPrimary() prints the answer, abstract and definition of a response in -priority order.
+// The abstract is always printed, as it has been since before the other fields were supported
+func printPrimary(w io.Writer, input Response) {
+	for _, field := range answerPriority {
+		label, text := primaryText(input, field)
+
+		if label == "" {
+			printAbstract(w, text, input.AbstractSource)
+			continue
+		}
+
+		if text == "" {
+			continue
+		}
+
+		fmt.Fprintln(w, color("Green"), blank()+label+":")
+		fmt.Fprintln(w, color("White"), indent()+text)
+
+		if field == "definition" && input.DefinitionURL != "" {
+			fmt.Fprintln(w, color("Blue"), indent()+input.DefinitionURL)
+		}
+
+		fmt.Fprint(w, color("Reset"))
+	}
+}
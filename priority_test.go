@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+		err   string
+	}{
+		{"answer,abstract,definition", []string{"answer", "abstract", "definition"}, ""},
+		{" Definition , answer", []string{"definition", "answer", "abstract"}, ""},
+		{"abstract", []string{"abstract", "answer", "definition"}, ""},
+		{"", []string{"answer", "abstract", "definition"}, ""},
+		{"answer,summary", nil, `Invalid -priority field "summary": expected one of answer, abstract, definition`},
+		{"answer,answer", nil, `Invalid -priority: "answer" is listed more than once`},
+	}
+
+	for _, test := range tests {
+		got, err := parsePriority(test.value)
+
+		switch {
+		case test.err != "" && (err == nil || err.Error() != test.err):
+			t.Errorf("parsePriority(%q) returned the error %v, want %q", test.value, err, test.err)
+		case test.err == "" && (err != nil || !reflect.DeepEqual(got, test.want)):
+			t.Errorf("parsePriority(%q) = %q, %v, want %q", test.value, got, err, test.want)
+		}
+	}
+}
+
+func TestPriorityOrder(t *testing.T) {
+	api := fixtureServer(t, "answers.json")
+
+	fields := map[string]string{
+		"answer":     "Answer:",
+		"abstract":   "Pi is a mathematical constant.",
+		"definition": "Definition:",
+	}
+
+	for _, order := range []string{"", "definition,answer", "abstract,definition,answer"} {
+		args := []string{"-api-base", api, "pi"}
+		if order != "" {
+			args = append([]string{"-priority", order}, args...)
+		}
+
+		stdout, stderr, code := runMain(t, "", args...)
+		if code != exitFound || stderr != "" {
+			t.Fatalf("-priority %q exited with %d and printed %q", order, code, stderr)
+		}
+
+		want, _ := parsePriority(order)
+		previous := -1
+		for _, field := range want {
+			at := strings.Index(stdout, fields[field])
+			if at < 0 {
+				t.Errorf("-priority %q did not print the %s:\n%s", order, field, stdout)
+			} else if at < previous {
+				t.Errorf("-priority %q printed %s out of order:\n%s", order, field, stdout)
+			}
+			previous = at
+		}
+	}
+
+	_, stderr, code := runMain(t, "", "-api-base", api, "-priority", "answer,image", "pi")
+	if code != exitUsage || !strings.Contains(stderr, `Invalid -priority field "image"`) {
+		t.Errorf("-priority answer,image exited with %d and printed %q, want %d", code, stderr, exitUsage)
+	}
+}
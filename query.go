@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// queryShortcuts are the prefixes expanded by -shortcuts. Prefixes ending in a colon are typed
+// directly before the search term, e.g. "w:golang" becomes "!w golang", while prefixes ending in a
+// space are a leading word, e.g. "def word" becomes "define word". Add an entry to support more
+var queryShortcuts = map[string]string{
+	"w:":   "!w ",   // Wikipedia
+	"g:":   "!g ",   // Google
+	"gh:":  "!gh ",  // GitHub
+	"yt:":  "!yt ",  // YouTube
+	"mdn:": "!mdn ", // MDN Web Docs
+	"def ": "define ",
+}
+
+// preprocessQuery() rewrites the user's input before it is sent to the API
+func preprocessQuery(query string) string {
+	query = strings.TrimSpace(singleLine(query))
+
+	if *flagShortcuts {
+		query = expandShortcuts(query)
+	}
+
+	if domain, ok := urlDomain(query); ok && *flagURLAware {
+		query = domain
+	}
+
+	return lowerBangs(query)
+}
+
+// singleLine() reduces a query of several lines, such as a heredoc given to -s, to a single line:
+// by default the first line that is not blank, or with -join-lines every line joined by spaces
+func singleLine(query string) string {
+	if !strings.ContainsAny(query, "\r\n") {
+		return query
+	}
+
+	if *flagJoinLines {
+		return strings.Join(strings.Fields(query), " ")
+	}
+
+	for _, line := range strings.FieldsFunc(query, func(r rune) bool { return r == '\n' || r == '\r' }) {
+		if strings.TrimSpace(line) != "" {
+			return line
+		}
+	}
+
+	return ""
+}
+
+// lowerBangs() lowercases the bang commands in a query, e.g. "!W Golang" becomes "!w Golang".
+// Bangs are case-insensitive on DuckDuckGo, so this only makes equal queries look equal. Every
+// other word keeps its case, as does a lone "!"
+func lowerBangs(query string) string {
+	words := strings.Split(query, " ")
+	for i, word := range words {
+		if len(word) > 1 && strings.HasPrefix(word, "!") {
+			words[i] = strings.ToLower(word)
+		}
+	}
+
+	return strings.Join(words, " ")
+}
+
+// expandShortcuts() replaces the first matching prefix from queryShortcuts, longest prefix first,
+// and leaves the query untouched when none match
+func expandShortcuts(query string) string {
+	prefixes := make([]string, 0, len(queryShortcuts))
+	for prefix := range queryShortcuts {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+
+	lowered := strings.ToLower(query)
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(lowered, prefix) && len(query) > len(prefix) {
+			return queryShortcuts[prefix] + strings.TrimSpace(query[len(prefix):])
+		}
+	}
+
+	return query
+}
+
+// questionPrefixes are stripped from the start of a query by rephraseQuery(). They are tried in
+// order, so "what is a" comes before "what is" and is removed whole rather than leaving "a" behind
+var questionPrefixes = []string{
+	"what is a ", "what is an ", "what is the ", "what is ", "what are ", "what was ",
+	"who is ", "who was ", "who are ", "where is ", "how to ", "how do i ", "how does ",
+	"define ", "meaning of ",
+}
+
+// rephraseQuery() rewrites a query that returned nothing, for -auto-retry-empty. There are two
+// rules, tried in order:
+//
+//	a question is reduced to its subject, "What is a monad?" becomes "monad"
+//	a single word is looked up as a definition, "monad" becomes "monad definition"
+//
+// An empty string is returned when neither rule applies
+func rephraseQuery(query string) string {
+	trimmed := strings.TrimSpace(strings.TrimRight(query, "?"))
+	lowered := strings.ToLower(trimmed)
+
+	for _, prefix := range questionPrefixes {
+		if strings.HasPrefix(lowered, prefix) && len(trimmed) > len(prefix) {
+			return strings.TrimSpace(trimmed[len(prefix):])
+		}
+	}
+
+	if trimmed != "" && !strings.ContainsAny(trimmed, " \t") && !strings.HasPrefix(trimmed, "!") {
+		return trimmed + " definition"
+	}
+
+	if trimmed != query {
+		return trimmed
+	}
+
+	return ""
+}
+
+// urlDomain() returns the domain of a query that is a bare URL, for -url-aware. The API knows
+// little about URLs but often has an abstract for the site, so the query is reduced to its host,
+// without a port or a leading "www.": "https://www.github.com/golang/go" becomes "github.com".
+// Only a single word with both a scheme and a host counts as a URL
+func urlDomain(query string) (string, bool) {
+	if query == "" || strings.ContainsAny(query, " \t") {
+		return "", false
+	}
+
+	link, err := url.Parse(query)
+	if err != nil || link.Scheme == "" || link.Hostname() == "" {
+		return "", false
+	}
+
+	return strings.TrimPrefix(strings.ToLower(link.Hostname()), "www."), true
+}
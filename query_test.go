@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRephraseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"What is a monad?", "monad"},
+		{"who was Ken Thompson", "Ken Thompson"},
+		{"how to exit vim?", "exit vim"},
+		{"monad", "monad definition"},
+		{"monad?", "monad definition"},
+		{"!gh golang", ""},
+		{"rob pike", ""},
+		{"what is", ""},
+	}
+
+	for _, test := range tests {
+		if got := rephraseQuery(test.query); got != test.want {
+			t.Errorf("rephraseQuery(%q) = %q, want %q", test.query, got, test.want)
+		}
+	}
+}
+
+func TestAutoRetryEmpty(t *testing.T) {
+	quietOutput(t)
+	setFlag(t, "auto-retry-empty", "true")
+
+	var notes bytes.Buffer
+	stderr = &notes
+
+	queries := []string{}
+	apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("q")
+		queries = append(queries, query)
+
+		w.Header().Set("Content-Type", "application/x-javascript")
+		if query == "monad" {
+			io.WriteString(w, `{"AbstractText":"A monad is a structure that combines program fragments.","RelatedTopics":[]}`)
+			return
+		}
+		io.WriteString(w, `{"AbstractText":"","RelatedTopics":[]}`)
+	})
+
+	response, err := answerQuery("What is a monad?", defaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Query != "monad" || response.AbstractText == "" {
+		t.Errorf("got the result for %q with the abstract %q, want the result for \"monad\"", response.Query, response.AbstractText)
+	}
+
+	if want := []string{"What is a monad?", "monad"}; strings.Join(queries, "|") != strings.Join(want, "|") {
+		t.Errorf("the stub was sent %q, want %q", queries, want)
+	}
+
+	if want := `No results for "What is a monad?", showing results for "monad"`; !strings.Contains(notes.String(), want) {
+		t.Errorf("the rewrite was not noted, stderr holds %q", notes.String())
+	}
+}
+
+func TestShortcuts(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"w:golang", "!w golang"},
+		{"g:rob pike", "!g rob pike"},
+		{"gh: golang/go", "!gh golang/go"},
+		{"yt:gophercon", "!yt gophercon"},
+		{"mdn:fetch", "!mdn fetch"},
+		{"def monad", "define monad"},
+		{"W:Golang", "!w Golang"},
+		// A prefix with nothing after it, or in the middle of the query, is left alone
+		{"w:", "w:"},
+		{"golang w:go", "golang w:go"},
+		{"default", "default"},
+	}
+
+	setFlag(t, "shortcuts", "true")
+	for _, test := range tests {
+		if got := preprocessQuery(test.query); got != test.want {
+			t.Errorf("preprocessQuery(%q) with -shortcuts = %q, want %q", test.query, got, test.want)
+		}
+	}
+
+	setFlag(t, "shortcuts", "false")
+	if got := preprocessQuery("w:golang"); got != "w:golang" {
+		t.Errorf("preprocessQuery(%q) without -shortcuts = %q", "w:golang", got)
+	}
+}
+
+func TestShortcutsSent(t *testing.T) {
+	sent := make(chan string, 1)
+	api := stubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		sent <- r.URL.Query().Get("q")
+		serveJSON(readFixture(t, "golang.json"))(w, r)
+	})
+
+	stdout, stderr, code := runMain(t, "", "-api-base", api, "-shortcuts", "def monad")
+	if code != exitFound || stderr != "" {
+		t.Fatalf("-shortcuts exited with %d and printed %q", code, stderr)
+	}
+
+	if q := <-sent; q != "define monad" {
+		t.Errorf("the API was sent q=%q, want %q", q, "define monad")
+	}
+
+	if !strings.Contains(stdout, "Go (programming language)") {
+		t.Errorf("-shortcuts did not print the response:\n%s", stdout)
+	}
+}
+
+func TestLowerBangs(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"!W Golang", "!w Golang"},
+		{"Golang !GH", "Golang !gh"},
+		{"!Wikipedia Rob Pike", "!wikipedia Rob Pike"},
+		// Only words starting with a bang are touched
+		{"! Golang", "! Golang"},
+		{"Hello! World", "Hello! World"},
+		{"Golang", "Golang"},
+	}
+
+	for _, test := range tests {
+		if got := preprocessQuery(test.query); got != test.want {
+			t.Errorf("preprocessQuery(%q) = %q, want %q", test.query, got, test.want)
+		}
+	}
+}
+
+func TestLowerBangsCache(t *testing.T) {
+	sent := make(chan string, 2)
+	api := stubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		sent <- r.URL.Query().Get("q")
+		serveJSON(readFixture(t, "golang.json"))(w, r)
+	})
+	cache := t.TempDir()
+
+	for _, query := range []string{"!W Golang", "!w Golang"} {
+		stdout, stderr, code := runMain(t, "", "-api-base", api, "-cache", "-cache-dir", cache, query)
+		if code != exitFound || stderr != "" || !strings.Contains(stdout, "Go (programming language)") {
+			t.Fatalf("%q exited with %d and printed %q, %q", query, code, stdout, stderr)
+		}
+	}
+
+	// The second query is answered from the entry of the first, whose search term kept its case
+	close(sent)
+	queries := []string{}
+	for q := range sent {
+		queries = append(queries, q)
+	}
+	if len(queries) != 1 || queries[0] != "!w Golang" {
+		t.Errorf("the API was sent %q, want only %q", queries, "!w Golang")
+	}
+}
+
+func TestMaxQueryLength(t *testing.T) {
+	var requests int32
+	api := stubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		serveJSON(readFixture(t, "golang.json"))(w, r)
+	})
+
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		// The length is counted in runes, ten of them take twenty bytes
+		{[]string{"-max-query-length", "10", strings.Repeat("ü", 10)}, ""},
+		{[]string{"-max-query-length", "10", strings.Repeat("ü", 11)}, "Query is too long: 11 characters, -max-query-length is 10"},
+		{[]string{strings.Repeat("a", 501)}, "Query is too long: 501 characters, -max-query-length is 500"},
+		{[]string{"-max-query-length", "0", strings.Repeat("a", 501)}, ""},
+	}
+
+	for _, test := range tests {
+		atomic.StoreInt32(&requests, 0)
+
+		_, stderr, code := runMain(t, "", append([]string{"-api-base", api}, test.args...)...)
+		sent := atomic.LoadInt32(&requests)
+
+		switch {
+		case test.err == "" && (code != exitFound || sent != 1):
+			t.Errorf("%.40q exited with %d after %d requests: %s", test.args, code, sent, stderr)
+		case test.err != "" && (code != exitError || sent != 0 || !strings.Contains(stderr, test.err)):
+			t.Errorf("%.40q exited with %d after %d requests and printed %q, want %q and no request", test.args, code, sent, stderr, test.err)
+		}
+	}
+}
+
+func TestURLDomain(t *testing.T) {
+	tests := []struct {
+		query  string
+		domain string
+		ok     bool
+	}{
+		{"https://www.github.com/golang/go", "github.com", true},
+		{"http://Go.dev:8080/doc", "go.dev", true},
+		{"ftp://ftp.gnu.org/", "ftp.gnu.org", true},
+		// Only a single word with a scheme and a host is a URL
+		{"github.com/golang/go", "", false},
+		{"https://github.com is great", "", false},
+		{"mailto:gopher@golang.org", "", false},
+		{"golang", "", false},
+		{"", "", false},
+	}
+
+	for _, test := range tests {
+		if domain, ok := urlDomain(test.query); domain != test.domain || ok != test.ok {
+			t.Errorf("urlDomain(%q) = %q, %v, want %q, %v", test.query, domain, ok, test.domain, test.ok)
+		}
+	}
+}
+
+func TestURLAware(t *testing.T) {
+	sent := make(chan string, 2)
+	api := stubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		sent <- r.URL.Query().Get("q")
+		serveJSON(readFixture(t, "golang.json"))(w, r)
+	})
+
+	link := "https://www.github.com/golang/go"
+
+	_, stderr, code := runMain(t, "", "-api-base", api, "-url-aware", "-s", link)
+	if code != exitFound || stderr != "Note: the query is a URL, searching for \"github.com\" instead\n" {
+		t.Errorf("-url-aware exited with %d and printed %q", code, stderr)
+	}
+	if q := <-sent; q != "github.com" {
+		t.Errorf("-url-aware sent q=%q, want %q", q, "github.com")
+	}
+
+	// Without the flag the URL is searched for as it is
+	_, stderr, code = runMain(t, "", "-api-base", api, "-s", link)
+	if code != exitFound || stderr != "" {
+		t.Errorf("without -url-aware the run exited with %d and printed %q", code, stderr)
+	}
+	if q := <-sent; q != link {
+		t.Errorf("without -url-aware q=%q was sent, want %q", q, link)
+	}
+}
+
+func TestSingleLine(t *testing.T) {
+	tests := []struct {
+		query     string
+		joinLines bool
+		want      string
+	}{
+		{"go programming", false, "go programming"},
+		{"\n  \ngo programming\nrob pike\n", false, "go programming"},
+		{"\r\n\r\ngo programming\r\nrob pike", false, "go programming"},
+		{"\n  \ngo programming\nrob pike\n", true, "go programming rob pike"},
+		{"go\r\n  programming\t\n", true, "go programming"},
+		{"\n \n", false, ""},
+	}
+
+	for _, test := range tests {
+		setFlag(t, "join-lines", strconv.FormatBool(test.joinLines))
+
+		if got := preprocessQuery(test.query); got != test.want {
+			t.Errorf("preprocessQuery(%q) with -join-lines=%v = %q, want %q", test.query, test.joinLines, got, test.want)
+		}
+	}
+}
+
+func TestSingleLineSent(t *testing.T) {
+	sent := make(chan string, 1)
+	api := stubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		sent <- r.URL.Query().Get("q")
+		serveJSON(readFixture(t, "golang.json"))(w, r)
+	})
+
+	heredoc := "\ngo programming\nrob pike\n"
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "go programming"},
+		{[]string{"-join-lines"}, "go programming rob pike"},
+	} {
+		_, stderr, code := runMain(t, "", append(append([]string{"-api-base", api}, test.args...), "-s", heredoc)...)
+		if code != exitFound || stderr != "" {
+			t.Fatalf("%q exited with %d and printed %q", test.args, code, stderr)
+		}
+
+		if q := <-sent; q != test.want {
+			t.Errorf("%q sent q=%q, want %q", test.args, q, test.want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// queryLogger appends a line per query to the -log-queries file. Every line is written with a
+// single call on a file opened for appending, and the concurrent query paths take turns, so
+// lines never interleave
+type queryLogger struct {
+	mu sync.Mutex
+}
+
+// queryLog is the -log-queries log of this run
+var queryLog = &queryLogger{}
+
+// queryLogFields replaces the characters that would break the tab separated format of a line
+var queryLogFields = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+
+// record() appends a query to the -log-queries file as a line of tab separated values: the time
+// in RFC 3339 format, the outcome (found, empty or error) and the query as it was typed
+func (l *queryLogger) record(query string, response Response, err error) {
+	if *flagLogQueries == "" {
+		return
+	}
+
+	outcome := "found"
+	switch {
+	case err != nil:
+		outcome = "error"
+	case isEmptyResult(response):
+		outcome = "empty"
+	}
+
+	line := fmt.Sprintf("%s\t%s\t%s\n", time.Now().Format(time.RFC3339), outcome, queryLogFields.Replace(strings.TrimSpace(query)))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.OpenFile(*flagLogQueries, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to write -log-queries: %v\n", err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(line); err != nil {
+		fmt.Fprintf(stderr, "Failed to write -log-queries: %v\n", err)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readQueryLog() splits the -log-queries file into its lines of tab separated fields, checking
+// that every line has a valid time
+func readQueryLog(t *testing.T, path string) [][]string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines [][]string
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			t.Fatalf("the line %q does not have 3 fields", line)
+		}
+		if _, err := time.Parse(time.RFC3339, fields[0]); err != nil {
+			t.Errorf("the line %q has an invalid time: %v", line, err)
+		}
+		lines = append(lines, fields[1:])
+	}
+
+	return lines
+}
+
+func TestLogQueries(t *testing.T) {
+	api := stubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("q") {
+		case "golang":
+			serveJSON(readFixture(t, "golang.json"))(w, r)
+		case "xyzzy":
+			serveJSON(readFixture(t, "empty.json"))(w, r)
+		default:
+			http.Error(w, "unavailable", http.StatusNotFound)
+		}
+	})
+
+	path := filepath.Join(t.TempDir(), "queries.tsv")
+	runMain(t, "golang\nxyzzy\nbroken\n", "-api-base", api, "-log-queries", path, "-abstract-only")
+
+	// A later run appends to the log
+	runMain(t, "", "-api-base", api, "-log-queries", path, "-abstract-only", "-s", "golang")
+
+	got := readQueryLog(t, path)
+	want := [][]string{{"found", "golang"}, {"empty", "xyzzy"}, {"error", "broken"}, {"found", "golang"}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("-log-queries wrote %q, want %q", got, want)
+	}
+
+	_, stderr, _ := runMain(t, "", "-api-base", api, "-log-queries", filepath.Join(t.TempDir(), "missing", "queries.tsv"), "-s", "golang")
+	if !strings.Contains(stderr, "Failed to write -log-queries: ") {
+		t.Errorf("-log-queries to a missing directory printed %q", stderr)
+	}
+}
+
+// TestLogQueriesConcurrent records many queries at once and checks that no line was torn. Run
+// it with -race
+func TestLogQueriesConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.tsv")
+	setFlag(t, "log-queries", path)
+
+	const queries = 50
+	query := func(i int) string {
+		return fmt.Sprintf("query %d %s", i, strings.Repeat("x", 4096))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < queries; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			queryLog.record(query(i)+"\twith a\ttab", Response{}, errors.New("failed"))
+		}(i)
+	}
+	wg.Wait()
+
+	lines := readQueryLog(t, path)
+	if len(lines) != queries {
+		t.Fatalf("the log holds %d lines, want %d", len(lines), queries)
+	}
+
+	seen := map[string]bool{}
+	for _, line := range lines {
+		seen[line[1]] = true
+	}
+	for i := 0; i < queries; i++ {
+		if !seen[query(i)+" with a tab"] {
+			t.Errorf("query %d is missing or torn", i)
+		}
+	}
+}
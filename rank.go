@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// rankStopWords are left out of the query terms used by -rank, they would match nearly any topic
+var rankStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "for": true, "in": true, "is": true, "of": true,
+	"on": true, "the": true, "to": true, "what": true, "who": true,
+}
+
+// rankTerms() splits text into lowercase words, dropping punctuation and -rank's stop words
+func rankTerms(text string) []string {
+	terms := []string{}
+
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if !rankStopWords[word] {
+			terms = append(terms, word)
+		}
+	}
+
+	return terms
+}
+
+// topicScore() is the -rank heuristic, the API itself does not score topics. Each distinct query
+// term scores 1 when it is a word of the topic's text, and 1 more when it is also a word of the
+// page the topic links to, e.g. "Go Programming Language" for .../Go_Programming_Language
+func topicScore(topic RelatedTopic, terms []string) int {
+	words := map[string]bool{}
+	for _, word := range rankTerms(topic.Text) {
+		words[word] = true
+	}
+
+	page := map[string]bool{}
+	for _, word := range rankTerms(topicQuery(topic)) {
+		page[word] = true
+	}
+
+	score := 0
+	seen := map[string]bool{}
+	for _, term := range terms {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		if words[term] {
+			score++
+		}
+		if page[term] {
+			score++
+		}
+	}
+
+	return score
+}
+
+// rankTopics() returns a copy of the topics ordered by topicScore() for query, highest first,
+// for -rank. Topics that score the same keep the order of the API. The topics inside each
+// category are ranked as well, and a category is placed by the score of its best topic
+func rankTopics(topics []RelatedTopic, query string) []RelatedTopic {
+	terms := rankTerms(query)
+
+	var rank func(topics []RelatedTopic) []RelatedTopic
+	rank = func(topics []RelatedTopic) []RelatedTopic {
+		ranked := make([]RelatedTopic, len(topics))
+		copy(ranked, topics)
+
+		for i := range ranked {
+			if len(ranked[i].Topics) == 0 {
+				ranked[i].score = topicScore(ranked[i], terms)
+				continue
+			}
+
+			ranked[i].Topics = rank(ranked[i].Topics)
+			ranked[i].score = ranked[i].Topics[0].score
+		}
+
+		sort.SliceStable(ranked, func(a, b int) bool {
+			return ranked[a].score > ranked[b].score
+		})
+
+		return ranked
+	}
+
+	return rank(topics)
+}
+
+// scoreLabel() is the " (score N)" annotation of a ranked topic shown with -show-scores
+func scoreLabel(topic RelatedTopic) string {
+	if !*flagShowScores {
+		return ""
+	}
+
+	return fmt.Sprintf(" (score %d)", topic.score)
+}
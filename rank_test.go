@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRankTopics(t *testing.T) {
+	topic := func(page string, text string) RelatedTopic {
+		return RelatedTopic{FirstURL: "https://duckduckgo.com/" + page, Text: text}
+	}
+
+	topics := []RelatedTopic{
+		topic("Limbo", "Limbo A programming language"),
+		topic("Rob_Pike", "Rob Pike Canadian programmer, co-creator of Go"),
+		topic("Plan_9", "Plan 9 An operating system"),
+		topic("Ken_Thompson", "Ken Thompson American pioneer of computer science"),
+		{Name: "People", Topics: []RelatedTopic{
+			topic("Robert_Griesemer", "Robert Griesemer Swiss computer scientist"),
+			topic("Ken_Thompson_(disambiguation)", "Ken Thompson may refer to several people"),
+		}},
+	}
+
+	ranked := rankTopics(topics, "the Ken Thompson of Go")
+
+	// Ken Thompson: 2 words in the text and 2 in the page, the category follows its best topic,
+	// then Rob Pike with "go" in its text, and the rest in the order of the API
+	got := []string{}
+	for _, topic := range ranked {
+		if len(topic.Topics) > 0 {
+			got = append(got, topic.Name)
+			continue
+		}
+		got = append(got, topicQuery(topic))
+	}
+
+	want := []string{"Ken Thompson", "People", "Rob Pike", "Limbo", "Plan 9"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ranked the topics as %q, want %q", got, want)
+	}
+
+	scores := []int{ranked[0].score, ranked[1].score, ranked[2].score, ranked[3].score, ranked[4].score}
+	if want := []int{4, 4, 1, 0, 0}; !reflect.DeepEqual(scores, want) {
+		t.Errorf("scored the topics %v, want %v", scores, want)
+	}
+
+	if nested := topicQuery(ranked[1].Topics[0]); nested != "Ken Thompson (disambiguation)" {
+		t.Errorf("the best topic of the category is %q, want it ranked first", nested)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// errTooManyRedirects stops a request that is redirected more than -max-redirects times, such as
+// by a misconfigured proxy or mirror that redirects in a loop
+var errTooManyRedirects = errors.New("Too many redirects")
+
+// checkRedirect() is the CheckRedirect of httpClient. It logs each redirect followed, so that
+// -v shows the whole chain, and gives up once there are more than -max-redirects of them
+func checkRedirect(request *http.Request, via []*http.Request) error {
+	logVerbose("redirect %d: %s -> %s", len(via), via[len(via)-1].URL, request.URL)
+
+	if len(via) > *flagMaxRedirects {
+		return fmt.Errorf("%w: gave up after %d instead of following the one to %s. The API or -api-base may be redirecting in a loop, -v lists the redirects", errTooManyRedirects, *flagMaxRedirects, request.URL)
+	}
+
+	return nil
+}
+
+// explainRedirects() drops the method and URL that http.Client adds to the error of a request
+// stopped by checkRedirect(), the error already names the URL it was last redirected to
+func explainRedirects(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+
+	return err
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// redirectServer() points -api-base at a stub that redirects each request to the next hop, and
+// only answers once a request has been redirected answerAt times. A negative answerAt loops forever
+func redirectServer(t *testing.T, answerAt int) *int32 {
+	var requests int32
+
+	apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		hop, _ := strconv.Atoi(r.URL.Query().Get("hop"))
+		if answerAt >= 0 && hop >= answerAt {
+			w.Header().Set("Content-Type", "application/x-javascript")
+			io.WriteString(w, `{"AbstractText":"Go is a programming language.","RelatedTopics":[]}`)
+			return
+		}
+
+		next := r.URL.Query()
+		next.Set("hop", strconv.Itoa(hop+1))
+		http.Redirect(w, r, "/?"+next.Encode(), http.StatusFound)
+	})
+
+	return &requests
+}
+
+func TestRedirectLoop(t *testing.T) {
+	setFlag(t, "max-redirects", "3")
+	setFlag(t, "retries", "2")
+
+	requests := redirectServer(t, -1)
+
+	_, err := queryResponse("golang", defaultOptions(), nil)
+	if !errors.Is(err, errTooManyRedirects) {
+		t.Fatalf("got the error %v, want %v", err, errTooManyRedirects)
+	}
+
+	if !strings.HasPrefix(err.Error(), "Too many redirects: gave up after 3 ") {
+		t.Errorf("the error does not name the cap: %v", err)
+	}
+
+	// The first request and the 3 redirects it may follow, a loop is not retried
+	if got := atomic.LoadInt32(requests); got != 4 {
+		t.Errorf("the stub was sent %d requests, want 4", got)
+	}
+}
+
+func TestRedirectsWithinCap(t *testing.T) {
+	setFlag(t, "max-redirects", "3")
+
+	redirectServer(t, 3)
+
+	response, err := queryResponse("golang", defaultOptions(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.AbstractText == "" {
+		t.Error("the answer at the end of the redirects was lost")
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// noRegion is the API's region code for results that are not tailored to any locale
+const noRegion = "wt-wt"
+
+// normalizeRegion() lowercases a region code such as "us-en" or "de-de" and checks that it has the
+// country-language shape the API expects. The API decides which codes it supports, an unknown code
+// that is well formed falls back to its unlocalized results
+func normalizeRegion(code string) (string, error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "" {
+		return "", nil
+	}
+
+	if len(code) != 5 || code[2] != '-' || !isLetters(code[:2]) || !isLetters(code[3:]) {
+		return "", fmt.Errorf("Invalid region %q: expected a code such as us-en, de-de or %s", code, noRegion)
+	}
+
+	return code, nil
+}
+
+func isLetters(text string) bool {
+	for _, r := range text {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// errQuit is returned by the :quit directive to end the session
+var errQuit = errors.New("quit")
+
+// session holds the state carried between queries in interactive mode
+type session struct {
+	options Options
+
+	// last is the most recent response, used by directives such as :open
+	last *Response
+
+	// lastQuery is the most recent query, searched for again by an empty line with -repeat-on-empty
+	lastQuery string
+
+	// stopPrefetch cancels the -prefetch of the related topics of the last response, if any
+	stopPrefetch func()
+}
+
+// runInteractive() runs the search prompt until the user quits with :quit or end of input
+func runInteractive(options Options) {
+	s := &session{options: options}
+
+	fmt.Fprintln(os.Stderr, "Type :help for the interactive directives, :quit to exit")
+
+	for {
+		// Ask the user for a search query
+		userInput, err := searchPrompt()
+
+		if err == io.EOF {
+			fmt.Fprintln(os.Stderr)
+			break
+		}
+
+		// An empty line only cancels a running query, at the prompt it may repeat the last one
+		if err == errEmptyInput && *flagRepeatOnEmpty && s.lastQuery != "" {
+			fmt.Fprintf(os.Stderr, "Searching again for %q\n", s.lastQuery)
+			userInput, err = s.lastQuery, nil
+		}
+
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			continue
+		}
+
+		// Lines starting with a colon control the session rather than being searched for
+		if isDirective(userInput) {
+			err := s.runDirective(userInput)
+			if err == errQuit {
+				break
+			}
+
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+			}
+			continue
+		}
+
+		if err := s.search(userInput); err != nil {
+			fmt.Fprintln(stderr, err)
+		}
+	}
+
+	s.cancelPrefetch()
+
+	if !*flagNoSummary {
+		fmt.Fprintln(stderr, s.summary())
+	}
+}
+
+// errCancelled is returned by search() when the user abandoned the query
+var errCancelled = errors.New("Query cancelled")
+
+// search() answers a query typed during the session
+func (s *session) search(query string) error {
+	s.lastQuery = strings.TrimSpace(query)
+
+	// The prefetches of the last answer would only compete with the new query
+	s.cancelPrefetch()
+
+	response, err := s.cancellableSearch(query)
+	if err != nil {
+		return err
+	}
+
+	// Let the user drill down into one of the candidates of an ambiguous query
+	if *flagDisambig {
+		response = drillDownDisambiguation(response, s.options)
+	}
+
+	s.last = &response
+
+	if *flagPrefetch {
+		s.stopPrefetch = prefetchRelated(response, s.options)
+	}
+
+	return nil
+}
+
+// cancelPrefetch() cancels the prefetches still running, before a new query or the end of the session
+func (s *session) cancelPrefetch() {
+	if s.stopPrefetch != nil {
+		s.stopPrefetch()
+		s.stopPrefetch = nil
+	}
+}
+
+// cancellableSearch() runs the query in the background while watching the input, an empty line
+// or :cancel typed before the answer arrives cancels the request. Anything else typed meanwhile
+// is kept for the next prompt
+func (s *session) cancellableSearch(query string) (Response, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	queryParent = ctx
+	defer func() { queryParent = context.Background() }()
+
+	done := make(chan fetchResult, 1)
+	go func() {
+		response, err := processAPIRequest(query, s.options)
+		done <- fetchResult{response: response, err: err}
+	}()
+
+	input := stdinReader.next()
+	cancelled := false
+
+	for {
+		select {
+		case result := <-done:
+			if cancelled && result.err != nil {
+				return result.response, errCancelled
+			}
+			return result.response, result.err
+		case line, ok := <-input:
+			switch {
+			case !ok:
+				input = nil
+			case line.err == nil && isCancel(line.text) && !cancelled:
+				cancelled = true
+				cancel()
+			default:
+				stdinReader.unread(line)
+			}
+		}
+	}
+}
+
+// isCancel() reports whether a line typed while a query runs asks for the query to be cancelled
+func isCancel(line string) bool {
+	line = strings.TrimSpace(line)
+	return line == "" || line == ":cancel"
+}
+
+// summary() describes the session, e.g. "Session: 7 queries, 5 with answers, 1 error"
+func (s *session) summary() string {
+	counts := sessionStats.snapshot()
+
+	summary := fmt.Sprintf("Session: %s, %d with answers, %s",
+		plural(counts.Queries, "query", "queries"), counts.Answered(), plural(counts.Errors, "error", "errors"))
+	if counts.CacheHits > 0 {
+		summary += fmt.Sprintf(", %d answered from the cache", counts.CacheHits)
+	}
+
+	return summary
+}
+
+// plural() formats a count with the singular or plural form of a noun
+func plural(count int, singular string, pluralForm string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, singular)
+	}
+
+	return fmt.Sprintf("%d %s", count, pluralForm)
+}
+
+// isDirective() reports whether the input is an interactive directive such as ":open 2"
+func isDirective(input string) bool {
+	return strings.HasPrefix(strings.TrimSpace(input), ":")
+}
+
+// directive is an interactive command, typed at the search prompt instead of a query
+type directive struct {
+	// names are the ways of typing the directive, the first is the one shown by :help
+	names []string
+
+	usage, description string
+
+	run func(s *session, args []string) error
+}
+
+// directives are the interactive commands, in the order :help lists them. They are registered in
+// init() because :help itself reads the list
+var directives []directive
+
+func init() {
+	directives = []directive{
+		{[]string{":help", ":h"}, ":help", "lists the interactive directives", (*session).help},
+		{[]string{":quit", ":q"}, ":quit", "ends the session and prints a summary of it", func(*session, []string) error { return errQuit }},
+		{[]string{":cancel"}, ":cancel", "cancels the running query, as does an empty line", func(*session, []string) error { return errors.New("No query is running") }},
+		{[]string{":open"}, ":open [N]", "opens the abstract URL, or the Nth related topic, of the last result", (*session).open},
+		{[]string{":paste"}, ":paste", "reads a query of several lines, ended by a line holding only :end", (*session).paste},
+		{[]string{":save"}, ":save FILE", "writes the last result to a file, in the output format without colors", (*session).save},
+		{[]string{":region"}, ":region [CODE]", "shows or switches the region results are tailored to", (*session).region},
+		{[]string{":set"}, ":set [NAME [VALUE]]", "lists, shows or changes the options of the session", (*session).set},
+	}
+}
+
+// findDirective() looks up a directive by any of its names
+func findDirective(name string) (directive, bool) {
+	for _, d := range directives {
+		for _, known := range d.names {
+			if name == known {
+				return d, true
+			}
+		}
+	}
+
+	return directive{}, false
+}
+
+// runDirective() executes an interactive directive
+func (s *session) runDirective(input string) error {
+	fields := strings.Fields(input)
+
+	d, ok := findDirective(fields[0])
+	if !ok {
+		return fmt.Errorf("Unknown directive %q, type :help for a list", fields[0])
+	}
+
+	// Prefetches read the flags that :set changes, so stop them before any directive runs
+	s.cancelPrefetch()
+
+	return d.run(s, fields[1:])
+}
+
+// help() handles ":help", listing every directive with a short description
+func (s *session) help(args []string) error {
+	width := 0
+	for _, d := range directives {
+		if len(d.usage) > width {
+			width = len(d.usage)
+		}
+	}
+
+	for _, d := range directives {
+		fmt.Fprintf(os.Stderr, "%-*s  %s\n", width, d.usage, d.description)
+	}
+
+	return nil
+}
+
+// open() handles ":open N", opening the Nth related topic of the last result,
+// or the abstract's URL when no number is given
+func (s *session) open(args []string) error {
+	if s.last == nil {
+		return fmt.Errorf("Nothing to open yet, search for something first")
+	}
+
+	if len(args) == 0 {
+		if s.last.AbstractURL == "" {
+			return fmt.Errorf("The last result has no abstract URL to open")
+		}
+
+		return openURL(s.last.AbstractURL)
+	}
+
+	topics := flattenTopics(s.last.RelatedTopics)
+
+	number, err := strconv.Atoi(args[0])
+	if err != nil || number < 1 || number > len(topics) {
+		return fmt.Errorf("Invalid topic number %q, expected 1-%d", args[0], len(topics))
+	}
+
+	if topics[number-1].FirstURL == "" {
+		return fmt.Errorf("Topic %d has no URL to open", number)
+	}
+
+	return openURL(topics[number-1].FirstURL)
+}
+
+// save() handles ":save FILE", writing the last result to a file with the outputFormatter, which
+// :set format changes. Colors and other escape sequences are left out of the file
+func (s *session) save(args []string) (err error) {
+	if s.last == nil {
+		return fmt.Errorf("Nothing to save yet, search for something first")
+	}
+
+	if len(args) == 0 {
+		return fmt.Errorf("Missing file name, expected :save FILE")
+	}
+	path := strings.Join(args, " ")
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("Failed to save the result: %v", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("Failed to save the result: %v", closeErr)
+		}
+	}()
+
+	// The heading and abstract of a streamed result were printed apart from the rest, the file needs them too
+	response := *s.last
+	response.streamed = false
+
+	if err := outputFormatter.Format(newANSIStripper(file), response); err != nil {
+		return fmt.Errorf("Failed to save the result: %v", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Saved the result for %q to %s\n", response.Query, path)
+	return nil
+}
+
+// region() handles ":region CODE", tailoring the following queries to a locale such as "de-de",
+// and shows the current region when no code is given
+func (s *session) region(args []string) error {
+	if len(args) == 0 {
+		current := s.options.Region
+		if current == "" {
+			current = "the API default"
+		}
+
+		fmt.Fprintln(os.Stderr, "Region:", current)
+		return nil
+	}
+
+	region, err := normalizeRegion(args[0])
+	if err != nil {
+		return err
+	}
+
+	s.options.Region = region
+	fmt.Fprintln(os.Stderr, "Region set to", region)
+
+	return nil
+}
+
+// pasteEnd is the line that ends the query of :paste
+const pasteEnd = ":end"
+
+// paste() handles ":paste", reading lines until one holding only :end and searching for them
+// as a single query, e.g. to look up a quoted passage. Line breaks and runs of whitespace in
+// the passage become single spaces
+func (s *session) paste(args []string) error {
+	fmt.Fprintf(os.Stderr, "Paste the query, then end it with a line holding only %s\n", pasteEnd)
+
+	lines := []string{}
+	for {
+		line, err := stdinReader.readLine()
+		if strings.TrimSpace(line) == pasteEnd {
+			break
+		}
+
+		lines = append(lines, line)
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	query := strings.Join(strings.Fields(strings.Join(lines, " ")), " ")
+	if query == "" {
+		return fmt.Errorf("Nothing was pasted")
+	}
+
+	return s.search(query)
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// runSession() runs an interactive session reading input, and returns what it printed to
+// stdout and to stderr
+func runSession(t *testing.T, input io.Reader) (string, string) {
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previousInput, previousStderr, previousDiagnostics := stdinReader, os.Stderr, stderr
+	stdinReader = &lineInput{reader: bufio.NewReader(input)}
+	os.Stderr, stderr = writer, writer
+	defer func() { stdinReader, os.Stderr, stderr = previousInput, previousStderr, previousDiagnostics }()
+
+	printed := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		reader.Close()
+		printed <- string(data)
+	}()
+
+	stdout := captureStdout(t, func() { runInteractive(defaultOptions()) })
+	writer.Close()
+
+	return stdout, <-printed
+}
+
+func TestSessionStreams(t *testing.T) {
+	apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") == "golang" {
+			serveJSON(readFixture(t, "golang.json"))(w, r)
+			return
+		}
+
+		http.Error(w, "unavailable", http.StatusNotFound)
+	})
+	setFlag(t, "prompt", "ddg> ")
+
+	previous := sessionStats
+	sessionStats = &stats{}
+	t.Cleanup(func() { sessionStats = previous })
+
+	stdout, stderr := runSession(t, strings.NewReader("golang\nbroken\n:frobnicate\n:quit\n"))
+
+	// Only the answer is a result
+	if stdout != renderFixture(t, "golang.json") {
+		t.Errorf("the session printed to stdout\n%s\nwant only the answer\n%s", stdout, renderFixture(t, "golang.json"))
+	}
+
+	// The prompts, the failed query, the unknown directive and the summary are not
+	for _, want := range []string{
+		"Type :help for the interactive directives",
+		"\nddg> ",
+		"API returned non-JSON content",
+		`Unknown directive ":frobnicate"`,
+		"Session: 2 queries, 1 with answers, 1 error",
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("the session did not print %q to stderr:\n%s", want, stderr)
+		}
+	}
+
+	if strings.Count(stderr, "ddg> ") != 4 || strings.Contains(stderr, "Go is a statically typed") {
+		t.Errorf("the session printed to stderr\n%s", stderr)
+	}
+}
+
+func TestVerboseOnStderr(t *testing.T) {
+	api := fixtureServer(t, "unmodeled.json")
+
+	// The unmodeled fields of the fixture give -strict -v something to report
+	standard, _, _ := runMain(t, "", "-api-base", api, "rust")
+	stdout, stderr, code := runMain(t, "", "-api-base", api, "-strict", "-v", "rust")
+	if code != exitFound {
+		t.Fatalf("-strict -v exited with %d and printed %q", code, stderr)
+	}
+
+	if stdout != standard {
+		t.Errorf("-strict -v changed stdout to\n%s\nwant\n%s", stdout, standard)
+	}
+	if !strings.Contains(stderr, "verbose: unmodeled field") || strings.Contains(stderr, "Rust is a general-purpose") {
+		t.Errorf("-strict -v printed to stderr\n%s", stderr)
+	}
+
+	// Batch statistics are diagnostics too
+	stdout, stderr, _ = runMain(t, "rust\n", "-api-base", api, "-stats", "-abstract-only")
+	if stdout != "Rust is a general-purpose programming language.\n" || stderr == "" {
+		t.Errorf("-stats printed %q to stdout and %q to stderr", stdout, stderr)
+	}
+}
+
+func TestHelp(t *testing.T) {
+	setFlag(t, "no-summary", "true")
+
+	stdout, stderr := runSession(t, strings.NewReader(":help\n:h\n:quit\n"))
+	if stdout != "" {
+		t.Errorf(":help printed to stdout:\n%s", stdout)
+	}
+
+	if !strings.HasPrefix(stderr, "Type :help for the interactive directives, :quit to exit\n") {
+		t.Errorf("the session does not start with the :help hint:\n%s", stderr)
+	}
+
+	// Both :help and :h list every registered directive with its description
+	width := len(":set [NAME [VALUE]]")
+	for _, d := range directives {
+		if line := fmt.Sprintf("%-*s  %s\n", width, d.usage, d.description); strings.Count(stderr, line) != 2 {
+			t.Errorf(":help does not list %s:\n%s", d.names[0], stderr)
+		}
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	apiServer(t, serveJSON(readFixture(t, "golang.json")))
+	setFlag(t, "no-summary", "true")
+
+	// The prompt is printed exactly as given, with or without a trailing space
+	for _, prompt := range []string{"ddg> ", "?", "Search: "} {
+		setFlag(t, "prompt", prompt)
+
+		stdout, stderr := runSession(t, strings.NewReader("golang\n:quit\n"))
+
+		if want := "\n" + prompt + "\n" + prompt; !strings.Contains(stderr, want) || strings.Count(stderr, prompt) != 2 {
+			t.Errorf("-prompt %q printed\n%q", prompt, stderr)
+		}
+		if strings.Contains(stdout, prompt) {
+			t.Errorf("-prompt %q was printed to stdout:\n%s", prompt, stdout)
+		}
+	}
+}
+
+func TestPaste(t *testing.T) {
+	var mutex sync.Mutex
+	queries := []string{}
+	apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		queries = append(queries, r.URL.Query().Get("q"))
+		mutex.Unlock()
+
+		serveJSON(readFixture(t, "golang.json"))(w, r)
+	})
+	setFlag(t, "no-summary", "true")
+
+	// Every line is there at once, so no blank line may follow a query: it would cancel it
+	input := ":paste\n  Go is a statically\n\ttyped,   compiled\n\nlanguage\n:end\ngolang\n:quit\n"
+	stdout, stderr := runSession(t, strings.NewReader(input))
+
+	// The passage is one query with its whitespace collapsed, the line after :end is the next query
+	mutex.Lock()
+	defer mutex.Unlock()
+	if want := []string{"Go is a statically typed, compiled language", "golang"}; !reflect.DeepEqual(queries, want) {
+		t.Errorf("the session sent %q, want %q", queries, want)
+	}
+
+	if strings.Count(stdout, "Go (programming language)") != 2 {
+		t.Errorf("the session printed\n%s", stdout)
+	}
+	if strings.Count(stderr, "end it with a line holding only :end") != 1 || strings.Contains(stderr, "cancelled") {
+		t.Errorf("the session printed to stderr\n%s", stderr)
+	}
+}
+
+func TestPasteNothing(t *testing.T) {
+	setFlag(t, "no-summary", "true")
+
+	stdout, stderr := runSession(t, strings.NewReader(":paste\n \n\n:end\n:paste\n"))
+	if stdout != "" || strings.Count(stderr, "Nothing was pasted\n") != 2 {
+		t.Errorf("pasting nothing printed %q, %q", stdout, stderr)
+	}
+}
+
+func TestCancelQuery(t *testing.T) {
+	for _, cancelLine := range []string{"", ":cancel"} {
+		t.Run(fmt.Sprintf("%q", cancelLine), func(t *testing.T) {
+			started, aborted := make(chan struct{}), make(chan struct{})
+
+			apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("q") == "slow" {
+					close(started)
+					<-r.Context().Done()
+					close(aborted)
+					return
+				}
+				serveJSON(readFixture(t, "golang.json"))(w, r)
+			})
+
+			input, typed := io.Pipe()
+			go func() {
+				defer typed.Close()
+
+				io.WriteString(typed, "slow\n")
+				<-started
+				io.WriteString(typed, cancelLine+"\n")
+
+				select {
+				case <-aborted:
+				case <-time.After(5 * time.Second):
+					t.Error("the slow request was not aborted")
+				}
+
+				// The session goes on with the next query
+				io.WriteString(typed, "golang\n")
+			}()
+
+			stdout, stderr := runSession(t, input)
+
+			if !strings.Contains(stderr, "Query cancelled") {
+				t.Errorf("the cancellation was not reported, stderr is %q", stderr)
+			}
+
+			if !strings.Contains(stdout, "Go is a statically typed") {
+				t.Errorf("the query after the cancelled one was not answered:\n%s", stdout)
+			}
+		})
+	}
+}
+
+func TestSave(t *testing.T) {
+	apiServer(t, serveJSON(readFixture(t, "golang.json")))
+
+	setFlag(t, "format", *flagFormat)
+	formatter := outputFormatter
+	t.Cleanup(func() { outputFormatter = formatter })
+
+	want := renderFixture(t, "golang.json")
+
+	// Colors are forced to check that they are left out of the file
+	setFlag(t, "color", "always")
+
+	dir := t.TempDir()
+	human, markdown := filepath.Join(dir, "golang.txt"), filepath.Join(dir, "golang.md")
+
+	input := strings.Join([]string{
+		":save " + human,
+		"golang",
+		":save " + human,
+		":set format markdown",
+		":save " + markdown,
+		":save",
+		":save " + filepath.Join(dir, "missing", "golang.txt"),
+	}, "\n") + "\n"
+
+	_, stderr := runSession(t, strings.NewReader(input))
+
+	for _, text := range []string{
+		"Nothing to save yet, search for something first\n",
+		fmt.Sprintf("Saved the result for %q to %s\n", "golang", human),
+		fmt.Sprintf("Saved the result for %q to %s\n", "golang", markdown),
+		"Missing file name, expected :save FILE\n",
+		"Failed to save the result: ",
+	} {
+		if !strings.Contains(stderr, text) {
+			t.Errorf("the session did not print %q, stderr is\n%s", text, stderr)
+		}
+	}
+
+	if got, err := os.ReadFile(human); err != nil || string(got) != want {
+		t.Errorf(":save wrote %q, %v, want the result without colors:\n%s", got, err, want)
+	}
+
+	if got, err := os.ReadFile(markdown); err != nil || !strings.HasPrefix(string(got), "## Go (programming language) ") {
+		t.Errorf(":save after :set format markdown wrote %q, %v", got, err)
+	}
+}
+
+func TestRepeatOnEmpty(t *testing.T) {
+	sent := make(chan string, 10)
+	apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		serveJSON(readFixture(t, "golang.json"))(w, r)
+		sent <- r.URL.Query().Get("q")
+	})
+	setFlag(t, "repeat-on-empty", "true")
+
+	input, typed := io.Pipe()
+	go func() {
+		defer typed.Close()
+
+		// Without a last query there is nothing to repeat
+		io.WriteString(typed, "\ngolang\n")
+		<-sent
+
+		// An empty line typed while the query runs would cancel it, so wait for the prompt
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(typed, "\n")
+
+		select {
+		case q := <-sent:
+			if q != "golang" {
+				t.Errorf("the repeated query sent q=%q", q)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("the empty line did not repeat the query")
+		}
+	}()
+
+	stdout, stderr := runSession(t, input)
+
+	if !strings.Contains(stderr, errEmptyInput.Error()) {
+		t.Errorf("an empty line before any query was not rejected, stderr is %q", stderr)
+	}
+
+	if strings.Count(stderr, `Searching again for "golang"`) != 1 || strings.Contains(stderr, "Query cancelled") {
+		t.Errorf("the query was not repeated once, stderr is %q", stderr)
+	}
+
+	if count := strings.Count(stdout, "Go is a statically typed"); count != 2 {
+		t.Errorf("printed %d results, want 2:\n%s", count, stdout)
+	}
+}
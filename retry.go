@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// retryDelay is the pause before each of the -retries attempts
+	retryDelay = time.Second
+
+	// dnsRetries extra attempts are made after a temporary DNS failure, even when -retries is 0,
+	// as resolvers commonly hiccup while a laptop wakes from sleep
+	dnsRetries    = 2
+	dnsRetryDelay = 500 * time.Millisecond
+
+	// maxEmptyRetries caps -retries-on-empty, an empty result is usually genuinely empty
+	maxEmptyRetries = 5
+	emptyRetryDelay = 500 * time.Millisecond
+)
+
+// retryBudget caps the retries of every query of the run together, for -retry-budget. It is
+// shared by the concurrent query paths, so all access goes through its methods
+type retryBudget struct {
+	mu        sync.Mutex
+	used      int
+	exhausted bool
+}
+
+// sessionRetries is the retry budget of this run
+var sessionRetries = &retryBudget{}
+
+// take() uses up one retry of the budget, and reports false once -retry-budget retries have been
+// made. A budget of 0 is unlimited
+func (b *retryBudget) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if *flagRetryBudget <= 0 {
+		return true
+	}
+
+	if b.used >= *flagRetryBudget {
+		if !b.exhausted {
+			b.exhausted = true
+			logVerbose("the -retry-budget of %d retries is exhausted, failed requests are no longer retried", *flagRetryBudget)
+		}
+		return false
+	}
+
+	b.used++
+	return true
+}
+
+// doWithRetries() sends the request, retrying network errors up to -retries times. Temporary
+// DNS failures get their own short retries unless -no-dns-retry is set, while a host that does
+// not exist is a permanent failure and is never retried, nor is a redirect loop. Nothing is
+// retried once the request's context has ended, or once the -retry-budget of the run is used up
+func doWithRetries(request *http.Request) (*http.Response, error) {
+	ctx := request.Context()
+
+	retries, dnsAttempts := 0, 0
+
+	for {
+		response, err := httpClient.Do(request)
+		if err == nil {
+			return response, nil
+		}
+
+		var dnsErr *net.DNSError
+		isDNSError := errors.As(err, &dnsErr)
+
+		switch {
+		case ctx.Err() != nil:
+			return nil, err
+		case isDNSError && dnsErr.IsNotFound:
+			return nil, err
+		case errors.Is(err, errTooManyRedirects):
+			return nil, err
+		case isDNSError && (dnsErr.IsTemporary || dnsErr.IsTimeout) && !*flagNoDNSRetry && dnsAttempts < dnsRetries && sessionRetries.take():
+			dnsAttempts++
+			logVerbose("temporary DNS failure, retrying (%d/%d): %v", dnsAttempts, dnsRetries, err)
+			if sleepContext(ctx, dnsRetryDelay) != nil {
+				return nil, err
+			}
+		case retries < *flagRetries && sessionRetries.take():
+			retries++
+			logVerbose("request failed, retrying (%d/%d): %v", retries, *flagRetries, err)
+			if sleepContext(ctx, retryDelay) != nil {
+				return nil, err
+			}
+		default:
+			return nil, err
+		}
+	}
+}
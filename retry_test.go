@@ -0,0 +1,197 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// emptyThenAnswer() points -api-base at a stub that sends an empty result to the first empties
+// requests and the answer to the rest, and returns the count of requests it was sent
+func emptyThenAnswer(t *testing.T, empties int32) *int32 {
+	var requests int32
+
+	apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-javascript")
+
+		if atomic.AddInt32(&requests, 1) <= empties {
+			io.WriteString(w, `{"AbstractText":"","RelatedTopics":[]}`)
+			return
+		}
+		io.WriteString(w, `{"AbstractText":"Go is a programming language.","RelatedTopics":[]}`)
+	})
+
+	return &requests
+}
+
+func TestRetriesOnEmpty(t *testing.T) {
+	quietOutput(t)
+
+	tests := []struct {
+		name     string
+		retries  string
+		empties  int32
+		answered bool
+		requests int32
+	}{
+		{"filled in by a retry", "2", 2, true, 3},
+		{"retries used up", "1", 2, false, 2},
+		{"answered at once", "2", 0, true, 1},
+		{"off", "0", 1, false, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setFlag(t, "retries-on-empty", test.retries)
+			requests := emptyThenAnswer(t, test.empties)
+
+			response, err := answerQuery("golang", defaultOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if answered := response.AbstractText != ""; answered != test.answered {
+				t.Errorf("answered is %v, want %v", answered, test.answered)
+			}
+
+			if got := atomic.LoadInt32(requests); got != test.requests {
+				t.Errorf("the stub was sent %d requests, want %d", got, test.requests)
+			}
+		})
+	}
+}
+
+// roundTripFunc is an http.RoundTripper made of a function, to inject transport failures
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+// failingDNS() makes every API request fail with dnsErr until failures requests have failed,
+// after which they reach the stub, and returns the count of attempts
+func failingDNS(t *testing.T, dnsErr *net.DNSError, failures int32) *int32 {
+	apiServer(t, serveJSON(`{"AbstractText":"Go is a programming language.","RelatedTopics":[]}`))
+
+	var attempts int32
+
+	previous := httpClient.Transport
+	httpClient.Transport = roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		if atomic.AddInt32(&attempts, 1) <= failures {
+			return nil, dnsErr
+		}
+		return http.DefaultTransport.RoundTrip(request)
+	})
+	t.Cleanup(func() { httpClient.Transport = previous })
+
+	return &attempts
+}
+
+func TestDNSRetries(t *testing.T) {
+	temporary := &net.DNSError{Err: "server misbehaving", Name: "api.duckduckgo.com", IsTemporary: true}
+	notFound := &net.DNSError{Err: "no such host", Name: "api.duckduckgo.com", IsNotFound: true}
+
+	tests := []struct {
+		name       string
+		dnsErr     *net.DNSError
+		failures   int32
+		noDNSRetry bool
+		attempts   int32
+		fails      bool
+	}{
+		{"temporary failure recovers", temporary, 1, false, 2, false},
+		{"temporary failure persists", temporary, 10, false, 1 + dnsRetries, true},
+		{"no such host", notFound, 10, false, 1, true},
+		{"-no-dns-retry", temporary, 1, true, 1, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setFlag(t, "retries", "0")
+			setFlag(t, "no-dns-retry", strconv.FormatBool(test.noDNSRetry))
+
+			attempts := failingDNS(t, test.dnsErr, test.failures)
+
+			_, err := queryResponse("golang", defaultOptions(), nil)
+			if (err != nil) != test.fails {
+				t.Errorf("got the error %v, want failure %v", err, test.fails)
+			}
+
+			if got := atomic.LoadInt32(attempts); got != test.attempts {
+				t.Errorf("the request was attempted %d times, want %d", got, test.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	temporary := &net.DNSError{Err: "server misbehaving", Name: "api.duckduckgo.com", IsTemporary: true}
+
+	tests := []struct {
+		name     string
+		budget   string
+		attempts int32
+	}{
+		{"caps every query together", "3", 4 + 3},
+		{"unlimited", "0", 4 * (1 + dnsRetries)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setFlag(t, "retries", "0")
+			setFlag(t, "retry-budget", test.budget)
+
+			previous := sessionRetries
+			sessionRetries = &retryBudget{}
+			t.Cleanup(func() { sessionRetries = previous })
+
+			attempts := failingDNS(t, temporary, 100)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func(query string) {
+					defer wg.Done()
+					if _, err := queryResponse(query, defaultOptions(), nil); err == nil {
+						t.Errorf("%s: the failing query succeeded", query)
+					}
+				}("golang " + strconv.Itoa(i))
+			}
+			wg.Wait()
+
+			if got := atomic.LoadInt32(attempts); got != test.attempts {
+				t.Errorf("the queries were attempted %d times, want %d", got, test.attempts)
+			}
+		})
+	}
+
+	t.Run("exhaustion is logged", func(t *testing.T) {
+		setFlag(t, "retries", "0")
+		setFlag(t, "retry-budget", "1")
+		setFlag(t, "v", "true")
+
+		previous := sessionRetries
+		sessionRetries = &retryBudget{}
+		t.Cleanup(func() { sessionRetries = previous })
+
+		failingDNS(t, temporary, 100)
+
+		var log strings.Builder
+		previousStderr := stderr
+		stderr = &log
+		t.Cleanup(func() { stderr = previousStderr })
+
+		for _, query := range []string{"golang", "rust", "python"} {
+			queryResponse(query, defaultOptions(), nil)
+		}
+
+		if count := strings.Count(log.String(), "-retry-budget of 1 retries is exhausted"); count != 1 {
+			t.Errorf("the exhaustion was logged %d times:\n%s", count, log.String())
+		}
+	})
+}
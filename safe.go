@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// safeSearchLevels map the -safe levels to the values of the API's kp parameter
+var safeSearchLevels = map[string]string{
+	"strict":   "1",
+	"moderate": "-1",
+	"off":      "-2",
+}
+
+// normalizeSafe() lowercases and checks a -safe level, an empty level leaves it to the API
+func normalizeSafe(level string) (string, error) {
+	level = strings.ToLower(strings.TrimSpace(level))
+	if _, ok := safeSearchLevels[level]; level != "" && !ok {
+		levels := make([]string, 0, len(safeSearchLevels))
+		for name := range safeSearchLevels {
+			levels = append(levels, name)
+		}
+		sort.Strings(levels)
+
+		return "", fmt.Errorf("Invalid safe search level %q: expected one of %s", level, strings.Join(levels, ", "))
+	}
+
+	return level, nil
+}
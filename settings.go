@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// setting is an option that can be changed during an interactive session with :set
+type setting struct {
+	get func(s *session) string
+	set func(s *session, value string) error
+}
+
+// settings are the options known to :set. Most are the command-line flag of the same name, add
+// an entry with flagSetting() to make another flag settable
+var settings = map[string]setting{
+	"color":            flagSetting("color", validateColorMode),
+	"compact":          flagSetting("compact", nil),
+	"limit":            flagSetting("limit", nil),
+	"max-per-category": flagSetting("max-per-category", nil),
+	"no-color":         flagSetting("no-color", nil),
+	"no-related":       flagSetting("no-related", nil),
+	"retries":          flagSetting("retries", nil),
+	"timeout":          flagSetting("timeout", validateTimeout),
+	"truecolor":        flagSetting("truecolor", nil),
+	"format": {
+		get: func(*session) string { return *flagFormat },
+		set: func(_ *session, value string) error {
+			formatter, ok := formatters[value]
+			if !ok {
+				return fmt.Errorf("Invalid format %q: expected one of %s", value, strings.Join(formatterNames(), ", "))
+			}
+
+			*flagFormat = value
+			outputFormatter = formatter
+			return nil
+		},
+	},
+	"region": {
+		get: func(s *session) string { return s.options.Region },
+		set: func(s *session, value string) error {
+			region, err := normalizeRegion(value)
+			if err != nil {
+				return err
+			}
+
+			s.options.Region = region
+			return nil
+		},
+	},
+	"safe": {
+		get: func(s *session) string { return s.options.Safe },
+		set: func(s *session, value string) error {
+			safe, err := normalizeSafe(value)
+			if err != nil {
+				return err
+			}
+
+			s.options.Safe = safe
+			return nil
+		},
+	},
+}
+
+// flagSetting() makes a command-line flag settable. The flag's own parsing rejects values of the
+// wrong type, validate may reject values that parse but make no sense
+func flagSetting(name string, validate func(string) error) setting {
+	return setting{
+		get: func(*session) string { return flag.Lookup(name).Value.String() },
+		set: func(_ *session, value string) error {
+			if validate != nil {
+				if err := validate(value); err != nil {
+					return err
+				}
+			}
+
+			// A flag that fails to parse may still have been overwritten, so put the old value back
+			previous := flag.Lookup(name).Value.String()
+			if err := flag.Set(name, value); err != nil {
+				flag.Set(name, previous)
+				return fmt.Errorf("Invalid value %q for %s: %v", value, name, err)
+			}
+			return nil
+		},
+	}
+}
+
+func validateTimeout(value string) error {
+	if timeout, err := time.ParseDuration(value); err == nil && timeout < 0 {
+		return fmt.Errorf("Invalid timeout %v: expected a positive duration", timeout)
+	}
+
+	return nil
+}
+
+// settingNames() lists the settings in alphabetical order
+func settingNames() []string {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// set() handles ":set NAME VALUE", changing an option for the following queries. ":set NAME"
+// shows the current value of one option and ":set" alone lists all of them
+func (s *session) set(args []string) error {
+	if len(args) == 0 {
+		for _, name := range settingNames() {
+			fmt.Fprintf(os.Stderr, "%s = %s\n", name, settings[name].get(s))
+		}
+		return nil
+	}
+
+	option, ok := settings[args[0]]
+	if !ok {
+		return fmt.Errorf("Unknown option %q, :set without arguments lists the options", args[0])
+	}
+
+	if len(args) == 1 {
+		fmt.Fprintf(os.Stderr, "%s = %s\n", args[0], option.get(s))
+		return nil
+	}
+
+	if len(args) > 2 {
+		return fmt.Errorf("Too many values for %s, expected :set %s VALUE", args[0], args[0])
+	}
+
+	if err := option.set(s, args[1]); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "%s = %s\n", args[0], option.get(s))
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureStderr() returns what run() prints to os.Stderr, where :set reports the settings
+func captureStderr(t *testing.T, run func()) string {
+	t.Helper()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := os.Stderr
+	os.Stderr = writer
+	defer func() { os.Stderr = previous }()
+
+	printed := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		reader.Close()
+		printed <- string(data)
+	}()
+
+	run()
+	writer.Close()
+
+	return <-printed
+}
+
+func TestSet(t *testing.T) {
+	// Put back every flag :set changes below
+	for _, name := range []string{"limit", "timeout", "color", "format"} {
+		setFlag(t, name, flag.Lookup(name).Value.String())
+	}
+	formatter := outputFormatter
+	t.Cleanup(func() { outputFormatter = formatter })
+
+	s := &session{options: defaultOptions()}
+
+	tests := []struct {
+		input  string
+		output string
+	}{
+		{":set limit 3", "limit = 3\n"},
+		{":set timeout 2s", "timeout = 2s\n"},
+		{":set region DE-DE", "region = de-de\n"},
+		{":set safe Off", "safe = off\n"},
+		{":set format markdown", "format = markdown\n"},
+		{":set limit", "limit = 3\n"},
+	}
+
+	for _, test := range tests {
+		var err error
+		output := captureStderr(t, func() { err = s.runDirective(test.input) })
+
+		if err != nil || output != test.output {
+			t.Errorf("%s printed %q and returned %v, want %q", test.input, output, err, test.output)
+		}
+	}
+
+	if *flagLimit != 3 || *flagTimeout != 2*time.Second || s.options.Region != "de-de" || s.options.Safe != "off" {
+		t.Errorf(":set left -limit %d, -timeout %v, region %q and safe %q", *flagLimit, *flagTimeout, s.options.Region, s.options.Safe)
+	}
+	if _, ok := outputFormatter.(MarkdownFormatter); !ok {
+		t.Errorf(":set format markdown selected %T", outputFormatter)
+	}
+
+	// :set alone lists every option in order
+	listed := captureStderr(t, func() { s.runDirective(":set") })
+	lines := strings.Split(strings.TrimSuffix(listed, "\n"), "\n")
+	if len(lines) != len(settings) || !strings.HasPrefix(lines[0], "color = ") || lines[len(lines)-1] != "truecolor = "+flag.Lookup("truecolor").Value.String() {
+		t.Errorf(":set listed\n%s", listed)
+	}
+}
+
+func TestSetInvalid(t *testing.T) {
+	setFlag(t, "limit", "5")
+
+	s := &session{options: defaultOptions()}
+
+	tests := map[string]string{
+		":set lmit 3":             `Unknown option "lmit", :set without arguments lists the options`,
+		":set limit three":        `Invalid value "three" for limit: `,
+		":set limit 3 4":          `Too many values for limit, expected :set limit VALUE`,
+		":set timeout -1s":        `Invalid timeout -1s: expected a positive duration`,
+		":set color sometimes":    `Invalid -color "sometimes"`,
+		":set region germany":     `Invalid region "germany"`,
+		":set safe maybe":         `Invalid safe search level "maybe"`,
+		":set format spreadsheet": `Invalid format "spreadsheet"`,
+	}
+
+	for input, want := range tests {
+		var err error
+		output := captureStderr(t, func() { err = s.runDirective(input) })
+
+		if err == nil || !strings.HasPrefix(err.Error(), want) || output != "" {
+			t.Errorf("%s printed %q and returned %v, want %q", input, output, err, want)
+		}
+	}
+
+	// A rejected value leaves the option as it was
+	if *flagLimit != 5 || s.options.Region != defaultOptions().Region || s.options.Safe != defaultOptions().Safe {
+		t.Errorf("the rejected values changed -limit to %d, region to %q and safe to %q", *flagLimit, s.options.Region, s.options.Safe)
+	}
+}
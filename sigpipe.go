@@ -0,0 +1,21 @@
+//go:build !js && !plan9
+// +build !js,!plan9
+
+package main
+
+import (
+	"errors"
+	"os/signal"
+	"syscall"
+)
+
+// ignoreSIGPIPE() has writes to a closed pipe reported as errors rather than the program being
+// killed by SIGPIPE, so that printing can stop cleanly
+func ignoreSIGPIPE() {
+	signal.Ignore(syscall.SIGPIPE)
+}
+
+// isBrokenPipe() reports whether a write failed because the reading end of the pipe was closed
+func isBrokenPipe(err error) bool {
+	return errors.Is(err, syscall.EPIPE)
+}
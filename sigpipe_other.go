@@ -0,0 +1,13 @@
+//go:build js || plan9
+// +build js plan9
+
+package main
+
+// ignoreSIGPIPE() does nothing on systems without SIGPIPE
+func ignoreSIGPIPE() {}
+
+// isBrokenPipe() recognizes no system error here, a closed output is still caught by
+// isClosedOutput() as io.ErrClosedPipe or os.ErrClosed
+func isBrokenPipe(err error) bool {
+	return false
+}
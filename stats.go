@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// stats counts the queries of the run, sent to the API or answered from the cache. It is shared
+// by every query path, including the concurrent ones of -compare-regions and -diff, so all
+// access goes through its methods
+type stats struct {
+	mu sync.Mutex
+
+	queries, cacheHits, errors, empty int
+
+	// latencies holds how long each query of the API took, for -stats
+	latencies []time.Duration
+}
+
+// sessionStats counts every query of this run
+var sessionStats = &stats{}
+
+// record() counts the outcome of one query of the API, which took elapsed
+func (s *stats) record(response Response, err error, elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queries++
+	s.latencies = append(s.latencies, elapsed)
+	switch {
+	case err != nil:
+		s.errors++
+	case isEmptyResult(response):
+		s.empty++
+	}
+}
+
+// recordCacheHit() counts a query that was answered from the cache without asking the API. It
+// counts as a query like any other, but adds no latency as nothing was sent
+func (s *stats) recordCacheHit(response Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queries++
+	s.cacheHits++
+	if isEmptyResult(response) {
+		s.empty++
+	}
+}
+
+// statsSnapshot is a copy of the counters of stats at one point in time
+type statsSnapshot struct {
+	Queries, CacheHits, Errors, Empty int
+
+	Latencies []time.Duration
+}
+
+// Answered is the number of queries that found something
+func (s statsSnapshot) Answered() int {
+	return s.Queries - s.Errors - s.Empty
+}
+
+func (s *stats) snapshot() statsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	latencies := append([]time.Duration(nil), s.latencies...)
+
+	return statsSnapshot{Queries: s.queries, CacheHits: s.cacheHits, Errors: s.errors, Empty: s.empty, Latencies: latencies}
+}
+
+// latencySummary() describes the latencies of the queries, e.g.
+// "Latency of 20 queries: mean 212ms, p50 180ms, p90 350ms, p99 1.2s"
+func (s statsSnapshot) latencySummary() string {
+	if len(s.Latencies) == 0 {
+		return "Latency: no queries were sent"
+	}
+
+	sorted := append([]time.Duration(nil), s.Latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, latency := range sorted {
+		total += latency
+	}
+	mean := total / time.Duration(len(sorted))
+
+	return fmt.Sprintf("Latency of %s: mean %v, p50 %v, p90 %v, p99 %v",
+		plural(len(sorted), "query", "queries"), round(mean), round(percentile(sorted, 50)),
+		round(percentile(sorted, 90)), round(percentile(sorted, 99)))
+}
+
+// percentile() returns the pth percentile of sorted latencies by the nearest-rank method, the
+// smallest latency that at least p percent of the queries were as fast as
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
+
+// round() shortens a latency to a readable precision
+func round(d time.Duration) time.Duration {
+	if d > time.Second {
+		return d.Round(time.Millisecond)
+	}
+
+	return d.Round(10 * time.Microsecond)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestStatsConcurrent fires queries from many goroutines at once and checks the session totals,
+// run it with -race
+func TestStatsConcurrent(t *testing.T) {
+	answer := readFixture(t, "golang.json")
+	apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch query := r.URL.Query().Get("q"); {
+		case strings.HasPrefix(query, "fail"):
+			// Not JSON, so the query fails
+			w.Header().Set("Content-Type", "text/html")
+		case strings.HasPrefix(query, "go"):
+			w.Header().Set("Content-Type", "application/x-javascript")
+			io.WriteString(w, answer)
+		default:
+			w.Header().Set("Content-Type", "application/x-javascript")
+			io.WriteString(w, `{"AbstractText":"","RelatedTopics":[]}`)
+		}
+	})
+
+	previous := sessionStats
+	sessionStats = &stats{}
+	t.Cleanup(func() { sessionStats = previous })
+
+	queries := []string{"golang", "nothing", "fail", "go programming", "fail again"}
+
+	const rounds = 10
+	fetchAll(rounds*len(queries), func(i int) (Response, error) {
+		sessionStats.snapshot()
+		return fetchResponse(queries[i%len(queries)], defaultOptions(), nil)
+	})
+
+	got := sessionStats.snapshot()
+	if got.Queries != rounds*5 || got.Answered() != rounds*2 || got.Empty != rounds || got.Errors != rounds*2 {
+		t.Errorf("got %d queries, %d answered, %d empty and %d errors, want %d, %d, %d and %d",
+			got.Queries, got.Answered(), got.Empty, got.Errors, rounds*5, rounds*2, rounds, rounds*2)
+	}
+
+	if len(got.Latencies) != got.Queries {
+		t.Errorf("got %d latencies for %d queries", len(got.Latencies), got.Queries)
+	}
+}
+
+func TestLatencySummary(t *testing.T) {
+	latencies := []time.Duration{}
+	for ms := 10; ms >= 1; ms-- {
+		latencies = append(latencies, time.Duration(ms)*time.Millisecond)
+	}
+
+	tests := []struct {
+		latencies []time.Duration
+		want      string
+	}{
+		{latencies, "Latency of 10 queries: mean 5.5ms, p50 5ms, p90 9ms, p99 10ms"},
+		{latencies[:1], "Latency of 1 query: mean 10ms, p50 10ms, p90 10ms, p99 10ms"},
+		{[]time.Duration{1234567 * time.Microsecond, 2 * time.Second}, "Latency of 2 queries: mean 1.617s, p50 1.235s, p90 2s, p99 2s"},
+		{nil, "Latency: no queries were sent"},
+	}
+
+	for _, test := range tests {
+		if got := (statsSnapshot{Latencies: test.latencies}).latencySummary(); got != test.want {
+			t.Errorf("latencySummary() of %v = %q, want %q", test.latencies, got, test.want)
+		}
+	}
+}
+
+func TestStatsBatch(t *testing.T) {
+	// Each query takes as many milliseconds as it says
+	api := stubAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		delay, _ := strconv.Atoi(r.URL.Query().Get("q"))
+		time.Sleep(time.Duration(delay) * time.Millisecond)
+
+		serveJSON(readFixture(t, "golang.json"))(w, r)
+	})
+
+	stdout, stderr, code := runMain(t, "20\n40\n80\n", "-api-base", api, "-stats", "-abstract-only")
+	if code != exitFound || strings.Count(stdout, "Go is a statically typed") != 3 || strings.Contains(stdout, "Latency") {
+		t.Fatalf("-stats exited with %d and printed\n%s", code, stdout)
+	}
+
+	match := regexp.MustCompile(`Latency of 3 queries: mean (\S+), p50 (\S+), p90 (\S+), p99 (\S+)\n`).FindStringSubmatch(stderr)
+	if match == nil {
+		t.Fatalf("-stats printed %q", stderr)
+	}
+
+	// The stub sets a floor under each percentile
+	for i, floor := range []time.Duration{46 * time.Millisecond, 40 * time.Millisecond, 80 * time.Millisecond, 80 * time.Millisecond} {
+		latency, err := time.ParseDuration(match[i+1])
+		if err != nil || latency < floor {
+			t.Errorf("-stats reported %s, want at least %v: %s", match[i+1], floor, match[0])
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// streamBody() reads a response body while decoding it token by token, printing the heading and
+// the primary results of the response to query as soon as they have arrived instead of after the
+// whole body, which on a slow connection may be long after. It returns the complete body for the
+// regular parse and whether they were printed. A body that cannot be decoded is still read in
+// full, so that the regular parse reports the problem
+func streamBody(body io.Reader, query string, w io.Writer) ([]byte, bool, error) {
+	var raw bytes.Buffer
+	tee := io.TeeReader(body, &raw)
+
+	streamed := streamPrimary(json.NewDecoder(tee), query, w)
+
+	// Read whatever the decoder has not asked for yet
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return nil, streamed, err
+	}
+
+	return raw.Bytes(), streamed, nil
+}
+
+// streamedFields is the last of the top-level fields printed before the related topics. The API
+// sends its fields in alphabetical order, so all of them have arrived by the time a field after it
+// does, while the long Infobox and RelatedTopics are still to come
+const streamedFields = "Heading"
+
+// streamPrimary() decodes the top-level fields of the response up to streamedFields, and prints
+// them as printResponse() would. Nothing is printed when there is no abstract to stream
+func streamPrimary(decoder *json.Decoder, query string, w io.Writer) bool {
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return false
+	}
+
+	partial := Response{Query: query}
+	fields := map[string]interface{}{
+		"AbstractSource": &partial.AbstractSource,
+		"AbstractText":   &partial.AbstractText,
+		"Answer":         &partial.Answer,
+		"Definition":     &partial.Definition,
+		"DefinitionURL":  &partial.DefinitionURL,
+		"Entity":         &partial.Entity,
+		"Heading":        &partial.Heading,
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+
+		key, _ := token.(string)
+		if key > streamedFields {
+			break
+		}
+
+		var target interface{} = &json.RawMessage{}
+		if field, ok := fields[key]; ok {
+			target = field
+		}
+
+		if err := decoder.Decode(target); err != nil {
+			return false
+		}
+	}
+
+	if partial.AbstractText == "" {
+		return false
+	}
+
+	// Clean the abstract as the regular parse does, it has not seen it yet
+	normalizeResponse(&partial)
+
+	printHeading(w, partial)
+	printPrimary(w, partial)
+
+	return true
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestStreamMatchesOutput(t *testing.T) {
+	apiServer(t, serveJSON(readFixture(t, "golang.json")))
+
+	answer := func() (string, Response) {
+		var response Response
+		printed := captureStdout(t, func() {
+			var err error
+			if response, err = answerQuery("golang", defaultOptions()); err != nil {
+				t.Error(err)
+			}
+		})
+
+		return printed, response
+	}
+
+	want, _ := answer()
+
+	setFlag(t, "stream", "true")
+	got, response := answer()
+	if !response.streamed {
+		t.Fatal("the abstract was not streamed")
+	}
+
+	if got != want {
+		t.Errorf("-stream printed\n%s\nwant\n%s", got, want)
+	}
+
+	if !strings.Contains(want, "Go (programming language) [programming language]") {
+		t.Errorf("the output has no heading:\n%s", want)
+	}
+}
+
+// TestStreamPrimaryEarly checks that the heading and abstract are printed from the start of the
+// body alone, before the related topics arrive
+func TestStreamPrimaryEarly(t *testing.T) {
+	body := readFixture(t, "golang.json")
+	start := body[:strings.Index(body, `"RelatedTopics"`)]
+
+	var out bytes.Buffer
+	if !streamPrimary(json.NewDecoder(strings.NewReader(start)), "golang", &out) {
+		t.Fatal("nothing was streamed")
+	}
+
+	for _, want := range []string{"Go (programming language) [programming language]", "Go is a statically typed", "— via Wikipedia"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("the streamed output has no %q:\n%s", want, out.String())
+		}
+	}
+}
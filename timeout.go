@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// queryDeadline is the wall-clock time given with -deadline, it is zero when no deadline was given
+var queryDeadline time.Time
+
+// parseDeadline() checks -deadline and -timeout, which are two ways of saying the same thing and
+// may not be combined, and parses the RFC3339 -deadline timestamp
+func parseDeadline(deadline string, timeout time.Duration) (time.Time, error) {
+	if deadline == "" {
+		if timeout < 0 {
+			return time.Time{}, fmt.Errorf("Invalid -timeout %v: expected a positive duration", timeout)
+		}
+		return time.Time{}, nil
+	}
+
+	if timeout != 0 {
+		return time.Time{}, errors.New("Invalid flags: -deadline and -timeout cannot be used together")
+	}
+
+	parsed, err := time.Parse(time.RFC3339, deadline)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid -deadline %q: expected an RFC3339 timestamp such as 2006-01-02T15:04:05Z", deadline)
+	}
+
+	return parsed, nil
+}
+
+// queryParent is the context every query is derived from. Interactive mode replaces it while a
+// query runs in the background, so that the query can be cancelled from the prompt
+var queryParent = context.Background()
+
+// queryContext() returns the context a single query derived from parent runs under, cancelled
+// after -timeout or at -deadline. Without either the query may take as long as the API does, as
+// it always has
+func queryContext(parent context.Context) (context.Context, context.CancelFunc) {
+	switch {
+	case !queryDeadline.IsZero():
+		return context.WithDeadline(parent, queryDeadline)
+	case *flagTimeout > 0:
+		return context.WithTimeout(parent, *flagTimeout)
+	}
+
+	return context.WithCancel(parent)
+}
+
+// sleepContext() pauses for the duration, returning early with the context's error when it ends first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
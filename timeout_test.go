@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPastDeadline(t *testing.T) {
+	previous := queryDeadline
+	queryDeadline = time.Now().Add(-time.Minute)
+	t.Cleanup(func() { queryDeadline = previous })
+
+	// The stub would keep the request waiting, a past deadline must not let it get that far
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+
+	start := time.Now()
+	_, err := queryResponse("golang", defaultOptions(), nil)
+	elapsed := time.Since(start)
+
+	if err == nil || !strings.HasPrefix(err.Error(), "Request did not finish in time") {
+		t.Fatalf("got the error %v, want the request to miss its deadline", err)
+	}
+
+	if elapsed > time.Second {
+		t.Errorf("the request was cancelled after %v, want at once", elapsed)
+	}
+}
+
+func TestParseDeadline(t *testing.T) {
+	if _, err := parseDeadline("2006-01-02T15:04:05Z", 5*time.Second); err == nil {
+		t.Error("-deadline and -timeout were accepted together")
+	}
+
+	if _, err := parseDeadline("tomorrow", 0); err == nil {
+		t.Error("a -deadline that is not RFC3339 was accepted")
+	}
+
+	got, err := parseDeadline("2006-01-02T15:04:05+01:00", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2006, 1, 2, 14, 4, 5, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("parseDeadline() = %v, want %v", got, want)
+	}
+}
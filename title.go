@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxTitleLength is the number of characters of the answer shown in the terminal title
+const maxTitleLength = 60
+
+// titleEnabled() reports whether -set-title may write to the terminal title: only when stdout is
+// a terminal and escape sequences are not disabled along with colors
+func titleEnabled() bool {
+	return *flagSetTitle && isTerminal(os.Stdout) && colorEnabled()
+}
+
+// titleText() is the part of a response shown in the terminal title: the answer, else the
+// abstract, else the title of the response, shortened to maxTitleLength characters
+func titleText(input Response) string {
+	text := string(input.Answer)
+	if text == "" {
+		text = input.AbstractText
+	}
+	if text == "" {
+		text = heading(input)
+	}
+
+	// The text comes from the API, it must not be able to end the escape sequence early
+	text = strings.Join(strings.Fields(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, text)), " ")
+
+	if utf8.RuneCountInString(text) > maxTitleLength {
+		text = string([]rune(text)[:maxTitleLength-1]) + "…"
+	}
+
+	return text
+}
+
+// setTitle() sets the terminal title with the OSC 0 escape sequence, for -set-title
+func setTitle(w io.Writer, input Response) {
+	fmt.Fprintf(w, "\033]0;%s\007", titleText(input))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTitleText(t *testing.T) {
+	long := strings.Repeat("x", maxTitleLength+10)
+
+	tests := []struct {
+		name  string
+		input Response
+		want  string
+	}{
+		{"answer first", Response{Answer: "42", AbstractText: "An abstract.", Heading: "Go"}, "42"},
+		{"then the abstract", Response{AbstractText: "An abstract.", Heading: "Go"}, "An abstract."},
+		{"then the heading", Response{Heading: "Go", Query: "golang"}, "Go"},
+		{"then the query", Response{Query: "golang"}, "golang"},
+		{"control characters", Response{AbstractText: "Bell\007 and\033]0;escape\n\tsequences"}, "Bell and ]0;escape sequences"},
+		{"shortened", Response{AbstractText: long}, long[:maxTitleLength-1] + "…"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := titleText(test.input); got != test.want {
+				t.Errorf("titleText() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSetTitle(t *testing.T) {
+	response, err := parseResponse("golang", readFixture(t, "golang.json"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	setTitle(&out, response)
+
+	if want := "\033]0;" + titleText(response) + "\007"; out.String() != want {
+		t.Errorf("setTitle() wrote %q, want %q", out.String(), want)
+	}
+}
+
+func TestTitleEnabled(t *testing.T) {
+	// /dev/null is a character device, so it stands in for a terminal
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer devNull.Close()
+
+	piped, pipeWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer piped.Close()
+	defer pipeWriter.Close()
+
+	tests := []struct {
+		name     string
+		stdout   *os.File
+		setTitle bool
+		noColor  string
+		color    string
+		want     bool
+	}{
+		{"enabled", devNull, true, "", "auto", true},
+		{"off", devNull, false, "", "auto", false},
+		{"piped", pipeWriter, true, "", "auto", false},
+		{"NO_COLOR", devNull, true, "1", "auto", false},
+		{"-color=never", devNull, true, "", "never", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setFlag(t, "set-title", strconv.FormatBool(test.setTitle))
+			setFlag(t, "color", test.color)
+			setEnv(t, "NO_COLOR", test.noColor)
+
+			previous := os.Stdout
+			os.Stdout = test.stdout
+			defer func() { os.Stdout = previous }()
+
+			if got := titleEnabled(); got != test.want {
+				t.Errorf("titleEnabled() = %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	// The output of the tests is piped, so the title is left alone
+	stdout, stderr, code := runMain(t, "", "-api-base", fixtureServer(t, "golang.json"), "-set-title", "-s", "golang")
+	if code != exitFound || strings.Contains(stdout, "\033]") {
+		t.Errorf("-set-title on piped output exited with %d and printed %q, %q", code, stdout, stderr)
+	}
+}
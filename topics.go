@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// flattenTopics() returns every topic in the list, replacing each category with the
+// topics nested inside of it
+func flattenTopics(topics []RelatedTopic) []RelatedTopic {
+	flat := []RelatedTopic{}
+
+	for _, topic := range topics {
+		if len(topic.Topics) > 0 {
+			flat = append(flat, flattenTopics(topic.Topics)...)
+			continue
+		}
+
+		flat = append(flat, topic)
+	}
+
+	return flat
+}
+
+// limitTopics() keeps the first limit topics, counting those nested inside categories, and drops
+// categories left empty. A limit of zero or less keeps every topic
+func limitTopics(topics []RelatedTopic, limit int) []RelatedTopic {
+	if limit <= 0 {
+		return topics
+	}
+
+	limited := []RelatedTopic{}
+
+	for _, topic := range topics {
+		if limit == 0 {
+			break
+		}
+
+		if len(topic.Topics) > 0 {
+			topic.Topics = limitTopics(topic.Topics, limit)
+			limit -= len(flattenTopics(topic.Topics))
+		} else {
+			limit--
+		}
+
+		limited = append(limited, topic)
+	}
+
+	return limited
+}
+
+// displayedTopics() returns the related topics of a response as every output format lists them:
+// numbered for -line-numbers, each category capped by -max-per-category and cut at -limit
+func displayedTopics(input Response) []RelatedTopic {
+	return limitTopics(capCategories(numberTopics(input.RelatedTopics), *flagMaxPerCategory), *flagLimit)
+}
+
+// capCategories() keeps at most max topics inside each category, recording how many were
+// left out so the output can mention them. A max of zero or less keeps every topic
+func capCategories(topics []RelatedTopic, max int) []RelatedTopic {
+	if max <= 0 {
+		return topics
+	}
+
+	capped := make([]RelatedTopic, len(topics))
+	copy(capped, topics)
+
+	for i := range capped {
+		if len(capped[i].Topics) == 0 {
+			continue
+		}
+
+		nested := flattenTopics(capped[i].Topics)
+		if len(nested) > max {
+			capped[i].Topics = nested[:max]
+			capped[i].hidden = len(nested) - max
+		}
+	}
+
+	return capped
+}
+
+// reverseTopics() returns the topics in reverse order, for -reverse. The topics inside each
+// category are reversed as well, so the last topic of all comes first
+func reverseTopics(topics []RelatedTopic) []RelatedTopic {
+	reversed := make([]RelatedTopic, 0, len(topics))
+
+	for i := len(topics) - 1; i >= 0; i-- {
+		topic := topics[i]
+		if len(topic.Topics) > 0 {
+			topic.Topics = reverseTopics(topic.Topics)
+		}
+
+		reversed = append(reversed, topic)
+	}
+
+	return reversed
+}
+
+// showRelated() reports whether the related topics of a response are printed: not with
+// -no-related, and with -topics-fallback only when there is no answer, abstract or definition
+func showRelated(input Response) bool {
+	if *flagNoRelated {
+		return false
+	}
+
+	if *flagTopicsFallback {
+		return input.Answer == "" && input.AbstractText == "" && input.Definition == ""
+	}
+
+	return true
+}
+
+// numberTopics() returns a copy of the topics numbered from 1 in the order used by -select and
+// :open, with the topics of categories flattened in. The numbers are kept by capCategories() and
+// limitTopics(), so a topic shows the same number however the list is trimmed
+func numberTopics(topics []RelatedTopic) []RelatedTopic {
+	next := 0
+
+	var number func(topics []RelatedTopic) []RelatedTopic
+	number = func(topics []RelatedTopic) []RelatedTopic {
+		numbered := make([]RelatedTopic, len(topics))
+		copy(numbered, topics)
+
+		for i := range numbered {
+			if len(numbered[i].Topics) > 0 {
+				numbered[i].Topics = number(numbered[i].Topics)
+				continue
+			}
+
+			next++
+			numbered[i].number = next
+		}
+
+		return numbered
+	}
+
+	return number(topics)
+}
+
+// numberWidth() is the number of digits of the highest topic number, so that numbers line up
+func numberWidth(topics []RelatedTopic) int {
+	highest := 0
+	for _, topic := range flattenTopics(topics) {
+		if topic.number > highest {
+			highest = topic.number
+		}
+	}
+
+	return len(strconv.Itoa(highest))
+}
+
+// numberLabel() returns the "N. " label of a topic right-aligned to width digits, and the
+// padding of the same length for the lines that follow it. Both are empty without -line-numbers
+func numberLabel(topic RelatedTopic, width int) (string, string) {
+	if !*flagLineNumbers || topic.number == 0 {
+		return "", ""
+	}
+
+	label := fmt.Sprintf("%*d. ", width, topic.number)
+	return label, strings.Repeat(" ", len(label))
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// topicLines() returns the URL lines of the related topics in human readable output, and the
+// lines of text under them
+func topicLines(out string) (urls []string, texts []string) {
+	lines := strings.Split(out, "\n")
+	for i, line := range lines {
+		if strings.Contains(line, "https://duckduckgo.com/") && i+1 < len(lines) {
+			urls = append(urls, line)
+			texts = append(texts, lines[i+1])
+		}
+	}
+
+	return urls, texts
+}
+
+func TestLineNumbers(t *testing.T) {
+	setFlag(t, "line-numbers", "true")
+
+	tests := []struct {
+		limit  string
+		topics int
+		width  int
+	}{
+		{"0", 12, 2},
+		{"9", 9, 1},
+	}
+
+	for _, test := range tests {
+		t.Run("-limit "+test.limit, func(t *testing.T) {
+			setFlag(t, "limit", test.limit)
+
+			out := renderFixture(t, "numbered.json")
+			urls, texts := topicLines(out)
+			if len(urls) != test.topics {
+				t.Fatalf("found %d topics, want %d:\n%s", len(urls), test.topics, out)
+			}
+
+			// The numbers are right-aligned to the widest one, and the text lines up with the URLs
+			column := strings.Index(urls[0], "https://")
+			for i, line := range urls {
+				label := fmt.Sprintf("\t%*d. https://", test.width, i+1)
+				if !strings.Contains(line, label) || strings.Index(line, "https://") != column {
+					t.Errorf("topic %d is %q, want the label %q in line with the others", i+1, line, label)
+				}
+
+				if text := texts[i]; len(text)-len(strings.TrimLeft(text, " \t")) != column {
+					t.Errorf("the text of topic %d is %q, want it to start under the URL", i+1, text)
+				}
+			}
+		})
+	}
+
+	setFlag(t, "line-numbers", "false")
+	setFlag(t, "limit", "0")
+	urls, _ := topicLines(renderFixture(t, "numbered.json"))
+	if !strings.HasSuffix(urls[0], "\thttps://duckduckgo.com/Alpha") {
+		t.Errorf("without -line-numbers the first topic is %q", urls[0])
+	}
+}
+
+func TestTopicsFallback(t *testing.T) {
+	setFlag(t, "topics-fallback", "true")
+
+	tests := []struct {
+		name    string
+		change  func(*Response)
+		related bool
+	}{
+		{"abstract", func(*Response) {}, false},
+		{"answer", func(r *Response) { r.AbstractText, r.Answer = "", "42" }, false},
+		{"definition", func(r *Response) { r.AbstractText, r.Definition = "", "A language." }, false},
+		{"nothing else", func(r *Response) { r.AbstractText = "" }, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, err := parseResponse("golang", readFixture(t, "golang.json"), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.change(&response)
+
+			var out bytes.Buffer
+			if err := printResponse(&out, response); err != nil {
+				t.Fatal(err)
+			}
+
+			if related := strings.Contains(out.String(), "Rob_Pike"); related != test.related {
+				t.Errorf("the topics are printed: %v, want %v:\n%s", related, test.related, out.String())
+			}
+		})
+	}
+
+	// -no-related still hides the topics of a result without an abstract
+	setFlag(t, "no-related", "true")
+	if showRelated(Response{}) {
+		t.Error("-no-related with -topics-fallback shows the topics")
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redactedValue replaces the value of sensitive headers written to the trace file
+const redactedValue = "[REDACTED]"
+
+// traceLog is the top level of the simplified HAR document written by -trace
+type traceLog struct {
+	Log struct {
+		Version string       `json:"version"`
+		Creator traceCreator `json:"creator"`
+		Entries []traceEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type traceCreator struct {
+	Name string `json:"name"`
+}
+
+// traceEntry describes a single API request and the response it received
+type traceEntry struct {
+	StartedDateTime time.Time     `json:"startedDateTime"`
+	Time            float64       `json:"time"`
+	Request         traceRequest  `json:"request"`
+	Response        traceResponse `json:"response"`
+	Timings         traceTimings  `json:"timings"`
+	Error           string        `json:"error,omitempty"`
+}
+
+type traceRequest struct {
+	Method  string        `json:"method"`
+	URL     string        `json:"url"`
+	Headers []traceHeader `json:"headers"`
+}
+
+type traceResponse struct {
+	Status     int           `json:"status"`
+	StatusText string        `json:"statusText"`
+	Headers    []traceHeader `json:"headers"`
+	BodySize   int64         `json:"bodySize"`
+}
+
+// traceTimings holds durations in milliseconds, wait is the time until the response headers
+// arrived and receive is the time spent reading the body
+type traceTimings struct {
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+type traceHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// traceTransport is an http.RoundTripper that records every request passing through it
+// and rewrites the trace file after each one, so the file is valid even if the program is killed
+type traceTransport struct {
+	next   http.RoundTripper
+	path   string
+	redact map[string]bool
+
+	mu  sync.Mutex
+	log traceLog
+}
+
+// newTraceTransport() creates a traceTransport writing to path, redacting the comma separated
+// list of header names in redact
+func newTraceTransport(next http.RoundTripper, path string, redact string) (*traceTransport, error) {
+	transport := &traceTransport{
+		next:   next,
+		path:   path,
+		redact: map[string]bool{},
+	}
+
+	for _, name := range strings.Split(redact, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			transport.redact[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+
+	transport.log.Log.Version = "1.2"
+	transport.log.Log.Creator.Name = "duckduckgo-answers"
+	transport.log.Log.Entries = []traceEntry{}
+
+	// Create the trace file up front so that an unwritable path is reported at startup
+	return transport, transport.write()
+}
+
+func (t *traceTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	entry := traceEntry{
+		StartedDateTime: time.Now(),
+		Request: traceRequest{
+			Method:  request.Method,
+			URL:     request.URL.String(),
+			Headers: t.headers(request.Header),
+		},
+	}
+
+	response, err := t.next.RoundTrip(request)
+	entry.Timings.Wait = milliseconds(time.Since(entry.StartedDateTime))
+
+	if err != nil {
+		entry.Error = err.Error()
+		entry.Time = entry.Timings.Wait
+		t.record(entry)
+		return nil, err
+	}
+
+	entry.Response = traceResponse{
+		Status:     response.StatusCode,
+		StatusText: http.StatusText(response.StatusCode),
+		Headers:    t.headers(response.Header),
+	}
+
+	// The entry is recorded once the body has been read and closed, so its size is known
+	response.Body = &tracedBody{ReadCloser: response.Body, transport: t, entry: entry}
+
+	return response, nil
+}
+
+// headers() converts headers into a sorted list, replacing the values of redacted headers
+func (t *traceTransport) headers(header http.Header) []traceHeader {
+	list := []traceHeader{}
+
+	for name, values := range header {
+		for _, value := range values {
+			if t.redact[http.CanonicalHeaderKey(name)] {
+				value = redactedValue
+			}
+			list = append(list, traceHeader{Name: name, Value: value})
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	return list
+}
+
+// record() appends the entry to the trace and rewrites the trace file
+func (t *traceTransport) record(entry traceEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.log.Log.Entries = append(t.log.Log.Entries, entry)
+
+	if err := t.writeLocked(); err != nil {
+		fmt.Fprintln(stderr, err)
+	}
+}
+
+func (t *traceTransport) write() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.writeLocked()
+}
+
+func (t *traceTransport) writeLocked() error {
+	data, err := json.MarshalIndent(t.log, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(t.path, data, 0644)
+}
+
+// tracedBody counts the bytes read from a response body and records its trace entry on Close
+type tracedBody struct {
+	io.ReadCloser
+	transport *traceTransport
+	entry     traceEntry
+	size      int64
+	closed    bool
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	err := b.ReadCloser.Close()
+
+	if !b.closed {
+		b.closed = true
+		b.entry.Response.BodySize = b.size
+		b.entry.Time = milliseconds(time.Since(b.entry.StartedDateTime))
+		b.entry.Timings.Receive = b.entry.Time - b.entry.Timings.Wait
+		b.transport.record(b.entry)
+	}
+
+	return err
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
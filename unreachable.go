@@ -0,0 +1,15 @@
+//go:build !plan9
+// +build !plan9
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isUnreachable() reports whether a connection failed because there is no route to the network
+// or host, as when the machine has no network at all
+func isUnreachable(err error) bool {
+	return errors.Is(err, syscall.ENETUNREACH) || errors.Is(err, syscall.EHOSTUNREACH)
+}
@@ -0,0 +1,9 @@
+//go:build plan9
+// +build plan9
+
+package main
+
+// isUnreachable() recognizes no system error here, appearsOffline() then relies on its DNS probe
+func isUnreachable(err error) bool {
+	return false
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// validationQuery is checked by -validate-url when no query is given, its characters all need
+// escaping in a URL
+const validationQuery = `C++ & "Go" 100% #1 ?a=b/ü`
+
+// expectedParams() lists every parameter the API URL of a query should carry and its value,
+// worked out from the options on their own rather than with getAPIURL()
+func expectedParams(query string, options Options) map[string]string {
+	expected := map[string]string{
+		"q":      query,
+		"format": options.Format,
+		"t":      "duckduckgo-answers",
+	}
+
+	for name, value := range map[string]int{
+		"pretty":        options.Pretty,
+		"no_redirect":   options.NoRedirect,
+		"no_html":       options.NoHTML,
+		"skip_disambig": options.SkipDisambig,
+	} {
+		if value != 0 {
+			expected[name] = strconv.Itoa(value)
+		}
+	}
+
+	if options.Region != "" {
+		expected["kl"] = options.Region
+	}
+
+	if kp, ok := safeSearchLevels[options.Safe]; ok {
+		expected["kp"] = kp
+	}
+
+	return expected
+}
+
+// validateURL() parses a built API URL back and compares it with -api-base and the expected
+// parameters, returning a description of every discrepancy. Parameters that are part of
+// -api-base are expected to be kept as they are
+func validateURL(apiURL string, expected map[string]string) []string {
+	problems := []string{}
+
+	parsed, err := url.Parse(apiURL)
+	if err != nil {
+		return append(problems, fmt.Sprintf("the URL does not parse: %v", err))
+	}
+
+	base, _ := url.Parse(*flagAPIBase)
+	if parsed.Scheme != base.Scheme || parsed.Host != base.Host || parsed.Path != base.Path {
+		problems = append(problems, fmt.Sprintf("the URL does not start with -api-base %s", *flagAPIBase))
+	}
+
+	params, err := url.ParseQuery(parsed.RawQuery)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("the query string is not correctly encoded: %v", err))
+	}
+
+	for name, values := range base.Query() {
+		if _, ok := expected[name]; !ok {
+			expected[name] = values[0]
+		}
+	}
+
+	names := make([]string, 0, len(expected))
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		values, ok := params[name]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s is missing, expected %q", name, expected[name]))
+		case len(values) > 1:
+			problems = append(problems, fmt.Sprintf("%s is given %d times", name, len(values)))
+		case values[0] != expected[name]:
+			problems = append(problems, fmt.Sprintf("%s is %q, expected %q", name, values[0], expected[name]))
+		}
+	}
+
+	for name := range params {
+		if _, ok := expected[name]; !ok {
+			problems = append(problems, fmt.Sprintf("%s is not expected", name))
+		}
+	}
+
+	return problems
+}
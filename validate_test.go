@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateURL(t *testing.T) {
+	setFlag(t, "api-base", "https://api.duckduckgo.com/?key=secret")
+
+	options := defaultOptions()
+	options.NoHTML = 1
+	options.Region = "de-de"
+	options.Safe = "strict"
+
+	apiURL := getAPIURL(validationQuery, options)
+	if problems := validateURL(apiURL, expectedParams(validationQuery, options)); len(problems) > 0 {
+		t.Fatalf("the built URL %s has problems: %q", apiURL, problems)
+	}
+
+	// Each corruption of the built URL is one the validator must notice
+	tests := []struct {
+		name    string
+		corrupt func(string) string
+		problem string
+	}{
+		{"unescaped plus", func(u string) string { return strings.Replace(u, "C%2B%2B", "C++", 1) }, `q is "C  `},
+		{"missing parameter", func(u string) string { return strings.Replace(u, "&kl=de-de", "", 1) }, `kl is missing, expected "de-de"`},
+		{"wrong value", func(u string) string { return strings.Replace(u, "kp=1", "kp=-2", 1) }, `kp is "-2", expected "1"`},
+		{"repeated parameter", func(u string) string { return u + "&no_html=1" }, "no_html is given 2 times"},
+		{"unexpected parameter", func(u string) string { return u + "&ia=web" }, "ia is not expected"},
+		{"base parameter dropped", func(u string) string { return strings.Replace(u, "key=secret&", "", 1) }, `key is missing, expected "secret"`},
+		{"bad escape", func(u string) string { return u + "&ia=%zz" }, "the query string is not correctly encoded"},
+		{"wrong host", func(u string) string { return strings.Replace(u, "api.duckduckgo.com", "example.com", 1) }, "the URL does not start with -api-base"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			corrupted := test.corrupt(apiURL)
+			if corrupted == apiURL {
+				t.Fatalf("the corruption did not change %s", apiURL)
+			}
+
+			problems := validateURL(corrupted, expectedParams(validationQuery, options))
+			if !strings.Contains(strings.Join(problems, "\n"), test.problem) {
+				t.Errorf("validateURL(%s) = %q, want a problem containing %q", corrupted, problems, test.problem)
+			}
+		})
+	}
+}
+
+func TestValidateURLFlag(t *testing.T) {
+	// Nothing is sent, so an API that cannot be reached does not matter
+	api := closedAPI(t)
+
+	stdout, stderr, code := runMain(t, "", "-api-base", api, "-validate-url", "-region", "us-en", "-s", "go & rust")
+	if code != exitFound || stderr != "" || !strings.HasPrefix(stdout, "URL ok: "+api+"?") {
+		t.Errorf("-validate-url exited with %d and printed %q, %q", code, stdout, stderr)
+	}
+
+	if !strings.Contains(stdout, "q=go+%26+rust") {
+		t.Errorf("the URL does not carry the query: %s", stdout)
+	}
+
+	// Without a query the sample query is checked
+	stdout, _, code = runMain(t, "", "-api-base", api, "-validate-url")
+	if code != exitFound || !strings.Contains(stdout, "C%2B%2B") {
+		t.Errorf("-validate-url without a query exited with %d and printed %q", code, stdout)
+	}
+}
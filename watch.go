@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// watchInterval is how often -watch-file checks the file for changes
+	watchInterval = 250 * time.Millisecond
+
+	// watchDebounce is how long the content of the file must stay the same before it is searched
+	// for, so that a file written in several steps is only searched for once it is complete
+	watchDebounce = 500 * time.Millisecond
+)
+
+// watchFile() searches for the content of the file at path whenever it changes, for -watch-file,
+// until the program is interrupted. The file is polled, which works on every system and for
+// files replaced by a rename. An empty or missing file is waited on without searching
+func watchFile(path string, options Options) error {
+	logVerbose("watching %s for queries", path)
+
+	seen, searched := "", ""
+	changed := time.Now()
+
+	for ; ; time.Sleep(watchInterval) {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Failed to read -watch-file: %v", err)
+		}
+
+		content := strings.TrimSpace(string(data))
+		if content != seen {
+			seen, changed = content, time.Now()
+		}
+
+		if seen == searched || time.Since(changed) < watchDebounce {
+			continue
+		}
+
+		searched = seen
+		if searched == "" {
+			continue
+		}
+
+		if _, err := processAPIRequest(searched, options); err != nil {
+			if err == errOutputClosed {
+				return nil
+			}
+			fmt.Fprintln(stderr, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchFile(t *testing.T) {
+	queries := make(chan string, 10)
+	apiServer(t, func(w http.ResponseWriter, r *http.Request) {
+		queries <- r.URL.Query().Get("q")
+		serveJSON(readFixture(t, "golang.json"))(w, r)
+	})
+
+	// next() waits for the next query sent to the API
+	next := func() string {
+		t.Helper()
+
+		select {
+		case query := <-queries:
+			return query
+		case <-time.After(5 * time.Second):
+			t.Fatal("no query was sent")
+			return ""
+		}
+	}
+
+	write := func(path string, content string) {
+		t.Helper()
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "query")
+
+	var err error
+	stdout := captureStdout(t, func() {
+		done := make(chan error, 1)
+		go func() { done <- watchFile(path, defaultOptions()) }()
+
+		// The file does not exist yet, which is waited on
+		time.Sleep(2 * watchInterval)
+		write(path, "golang\n")
+		if query := next(); query != "golang" {
+			t.Errorf("the first query is %q, want golang", query)
+		}
+
+		// A file written in quick steps is only searched for once it settles
+		write(path, "rust")
+		time.Sleep(watchInterval / 5)
+		write(path, "go programming")
+		if query := next(); query != "go programming" {
+			t.Errorf("the second query is %q, want the settled content", query)
+		}
+
+		// Writing the same content again is not a change
+		write(path, "  go programming\n")
+		time.Sleep(watchDebounce + 2*watchInterval)
+
+		// A directory cannot be read, which ends the watch
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Mkdir(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case err = <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("watchFile() did not stop")
+		}
+	})
+
+	if err == nil || !strings.HasPrefix(err.Error(), "Failed to read -watch-file: ") {
+		t.Errorf("watchFile() on a directory returned %v", err)
+	}
+
+	select {
+	case query := <-queries:
+		t.Errorf("the unchanged content was searched for again, as %q", query)
+	default:
+	}
+
+	if count := strings.Count(stdout, "Go is a statically typed"); count != 2 {
+		t.Errorf("printed %d results, want 2:\n%s", count, stdout)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package main
+
+// detectWidth() cannot ask the terminal for its size on this system, so terminalWidth() falls
+// back to COLUMNS and then to defaultTerminalWidth
+func detectWidth() (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,27 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// winsize is the terminal size reported by the TIOCGWINSZ ioctl
+type winsize struct {
+	rows, columns, xpixel, ypixel uint16
+}
+
+// detectWidth() asks the terminal on stdout for its number of columns
+func detectWidth() (int, bool) {
+	var size winsize
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, os.Stdout.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&size)))
+	if errno != 0 || size.columns == 0 {
+		return 0, false
+	}
+
+	return int(size.columns), true
+}